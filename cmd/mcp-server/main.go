@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
 	"time" // Import time package
@@ -8,12 +9,21 @@ import (
 	"github.com/gorilla/rpc/v2"
 	"github.com/gorilla/rpc/v2/json2"
 	"github.com/sire-run/sire/internal/mcp/api"
+	"github.com/sire-run/sire/internal/mcp/service"
 )
 
 func main() {
+	dbPath := flag.String("db-path", "", "Path to the BoltDB file of the executions to act on, e.g. to signal them; it is only opened during calls")
+	flag.Parse()
+
+	var opts []service.ToolProviderOption
+	if *dbPath != "" {
+		opts = append(opts, service.WithDatabase(*dbPath))
+	}
+
 	s := rpc.NewServer()
 	s.RegisterCodec(json2.NewCodec(), "application/json")
-	if err := s.RegisterService(api.NewService(), "mcp"); err != nil {
+	if err := s.RegisterService(api.NewService(opts...), "mcp"); err != nil {
 		log.Fatalf("Failed to register service: %v", err)
 	}
 
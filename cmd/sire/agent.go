@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/sire-run/sire/internal/agent"
+	"github.com/sire-run/sire/internal/core"
+	"github.com/sire-run/sire/internal/loader"
+	"github.com/sire-run/sire/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var agentInterval time.Duration
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Resume executions that are waiting for retries, timers or signals",
+	Long: `Run the agent, which periodically scans the database for executions left to it and
+resumes them: steps due for a retry, timer steps whose time has come, signalled steps and
+interrupted runs.
+
+The agent holds the database while it runs, so sire run and sire execution cannot open it
+at the same time. Sub-workflows referenced by file path are resolved relative to the
+current directory.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := storage.NewBoltDBStore(dbPath)
+		if err != nil {
+			fmt.Printf("Error initializing database: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() {
+			if err := store.Close(); err != nil {
+				fmt.Printf("Error closing database: %v\n", err)
+			}
+		}()
+
+		registry := core.NewWorkflowRegistry(func(path string) (*core.Workflow, error) {
+			return loader.Load(path)
+		})
+		engine := core.NewEngine(newDispatcher(), store, core.WithWorkflowResolver(registry))
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		agent.NewAgent(store, engine, agentInterval).Run(ctx)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(agentCmd)
+	agentCmd.Flags().DurationVar(&agentInterval, "interval", 5*time.Second, "How often to scan for executions to resume")
+	agentCmd.Flags().StringVarP(&dbPath, "db-path", "d", "sire.db", "Path to the BoltDB file for state persistence")
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/sire-run/sire/internal/core"
+	"github.com/sire-run/sire/internal/mcp/inprocess"
+	"github.com/sire-run/sire/internal/mcp/remote"
+
+	// Register the built-in sire:local tools with the in-process server.
+	_ "github.com/sire-run/sire/internal/nodes/file"
+	_ "github.com/sire-run/sire/internal/nodes/http"
+	_ "github.com/sire-run/sire/internal/nodes/transform"
+)
+
+// newDispatcher creates the dispatcher for workflow tools: sire:local tools run in-process
+// and mcp: tools are called on remote MCP servers.
+func newDispatcher() *core.DispatcherMux {
+	mux := core.NewDispatcherMux()
+	mux.Register("sire", inprocess.NewInProcessDispatcher())
+	mux.Register("mcp", remote.NewRemoteDispatcher())
+	return mux
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
 	"text/tabwriter" // New import for formatted output
 	"time"           // New import for time.Format
 
+	"github.com/sire-run/sire/internal/core"
 	"github.com/sire-run/sire/internal/storage" // New import for storage
 	"github.com/spf13/cobra"
 )
@@ -103,8 +108,20 @@ var statusCmd = &cobra.Command{
 		fmt.Printf("Execution ID: %s\n", exec.ID)
 		fmt.Printf("Workflow ID: %s\n", exec.WorkflowID)
 		fmt.Printf("Status: %s\n", exec.Status)
+		if exec.ParentExecutionID != "" {
+			fmt.Printf("Parent Execution ID: %s (step %s)\n", exec.ParentExecutionID, exec.ParentStepID)
+		}
 		fmt.Printf("Created At: %s\n", exec.CreatedAt.Format(time.RFC3339))
 		fmt.Printf("Updated At: %s\n", exec.UpdatedAt.Format(time.RFC3339))
+		if len(exec.Env) > 0 {
+			// Only the names: the values may be secrets.
+			names := make([]string, 0, len(exec.Env))
+			for name := range exec.Env {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Printf("Environment: %s\n", strings.Join(names, ", "))
+		}
 		fmt.Println("\nStep States:")
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
@@ -112,28 +129,130 @@ var statusCmd = &cobra.Command{
 			fmt.Printf("Error writing header: %v\n", err)
 			os.Exit(1)
 		}
-		for stepID, stepState := range exec.StepStates {
-			errmsg := ""
-			if stepState.Error != "" {
-				errmsg = stepState.Error
+		if err := printStepStates(w, store, exec, 0); err != nil {
+			fmt.Printf("Error writing step state: %v\n", err)
+			os.Exit(1)
+		}
+		if err := w.Flush(); err != nil {
+			fmt.Printf("Error flushing writer: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var cancelCmd = &cobra.Command{
+	Use:   "cancel [execution-id]",
+	Short: "Cancel a running or retrying workflow execution",
+	Long: `Cancel a running or retrying workflow execution.
+
+The database can only be opened by one process at a time, so this command cannot
+reach an execution while sire run or sire agent holds it; interrupt sire run first,
+which leaves the execution in the database, and then cancel it.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := storage.NewBoltDBStore(dbPath)
+		if err != nil {
+			fmt.Printf("Error initializing database: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() {
+			if err := store.Close(); err != nil {
+				fmt.Printf("Error closing database: %v\n", err)
 			}
-			if _, err := fmt.Fprintf(w, "%s\t%s\t%d\t%s\n",
-				stepID,
-				stepState.Status,
-				stepState.Attempts,
-				errmsg,
-			); err != nil {
-				fmt.Printf("Error writing step state: %v\n", err)
+		}()
+
+		executionID := args[0]
+		engine := core.NewEngine(nil, store)
+		if err := engine.Cancel(executionID); err != nil {
+			fmt.Printf("Error cancelling execution %s: %v\n", executionID, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Execution %s cancelled\n", executionID)
+	},
+}
+
+var signalData string
+
+var signalCmd = &cobra.Command{
+	Use:   "signal [execution-id] [step-id]",
+	Short: "Send a signal to a step waiting for one, e.g. an approval",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var payload map[string]interface{}
+		if signalData != "" {
+			if err := json.Unmarshal([]byte(signalData), &payload); err != nil {
+				fmt.Printf("Error parsing signal data: %v\n", err)
 				os.Exit(1)
 			}
 		}
-		if err := w.Flush(); err != nil {
-			fmt.Printf("Error flushing writer: %v\n", err)
+
+		store, err := storage.NewBoltDBStore(dbPath)
+		if err != nil {
+			fmt.Printf("Error initializing database: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() {
+			if err := store.Close(); err != nil {
+				fmt.Printf("Error closing database: %v\n", err)
+			}
+		}()
+
+		executionID, stepID := args[0], args[1]
+		engine := core.NewEngine(nil, store)
+		if err := engine.Signal(executionID, stepID, payload); err != nil {
+			fmt.Printf("Error signalling step %s of execution %s: %v\n", stepID, executionID, err)
 			os.Exit(1)
 		}
+		fmt.Printf("Signalled step %s of execution %s\n", stepID, executionID)
 	},
 }
 
+// printStepStates writes one row per step of exec, in step ID order. Compensated steps are
+// followed by a row for their compensation, and sub-workflow steps by the steps of their
+// child execution, indented one level deeper.
+func printStepStates(w io.Writer, store storage.Store, exec *core.Execution, depth int) error {
+	indent := strings.Repeat("  ", depth)
+	stepIDs := make([]string, 0, len(exec.StepStates))
+	for stepID := range exec.StepStates {
+		stepIDs = append(stepIDs, stepID)
+	}
+	sort.Strings(stepIDs)
+
+	for _, stepID := range stepIDs {
+		stepState := exec.StepStates[stepID]
+		if _, err := fmt.Fprintf(w, "%s%s\t%s\t%d\t%s\n",
+			indent,
+			stepID,
+			stepState.Status,
+			stepState.Attempts,
+			stepState.Error,
+		); err != nil {
+			return err
+		}
+
+		if c := stepState.Compensation; c != nil {
+			if _, err := fmt.Fprintf(w, "%s  [compensate]\t%s\t%d\t%s\n", indent, c.Status, c.Attempts, c.Error); err != nil {
+				return err
+			}
+		}
+
+		if stepState.ChildExecutionID == "" {
+			continue
+		}
+		child, err := store.LoadExecution(stepState.ChildExecutionID)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s  [execution %s]\t%s\t\t\n", indent, child.ID, child.Status); err != nil {
+			return err
+		}
+		if err := printStepStates(w, store, child, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -147,6 +266,9 @@ func init() {
 
 	executionCmd.AddCommand(listCmd)
 	executionCmd.AddCommand(statusCmd)
+	executionCmd.AddCommand(cancelCmd)
+	executionCmd.AddCommand(signalCmd)
+	signalCmd.Flags().StringVar(&signalData, "data", "", "JSON object sent as the signal payload, which becomes the step output")
 
 	// Add db-path flag to execution commands
 	executionCmd.PersistentFlags().StringVarP(&dbPath, "db-path", "d", "sire.db", "Path to the BoltDB file for state persistence")
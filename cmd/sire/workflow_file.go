@@ -0,0 +1,42 @@
+package main
+
+import (
+	"maps"
+	"path/filepath"
+
+	"github.com/sire-run/sire/internal/core"
+	"github.com/sire-run/sire/internal/loader"
+)
+
+// newWorkflowRegistry creates the resolver for sub-workflow steps of the workflow in rootFile.
+// The root workflow is registered by ID, and other references are loaded as files relative
+// to the root file's directory, with their environment variables resolved against env.
+func newWorkflowRegistry(rootFile string, root *core.Workflow, env *loader.Env) (*core.WorkflowRegistry, error) {
+	baseDir := filepath.Dir(rootFile)
+	registry := core.NewWorkflowRegistry(func(path string) (*core.Workflow, error) {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		return loader.Load(path, loader.WithEnv(env))
+	})
+	if root.ID != "" {
+		if err := registry.Register(root); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// loadEnv creates the environment that workflow files are interpolated with: the variables
+// of envFiles, later files overriding earlier ones, then the process environment.
+func loadEnv(envFiles []string) (*loader.Env, error) {
+	vars := make(map[string]string)
+	for _, file := range envFiles {
+		fileVars, err := loader.ReadEnvFile(file)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(vars, fileVars)
+	}
+	return loader.NewEnv(vars), nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sire-run/sire/internal/core"
+	"github.com/sire-run/sire/internal/graph"
+	"github.com/sire-run/sire/internal/loader"
+	"github.com/sire-run/sire/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	graphFile      string
+	graphFormat    string
+	graphExecution string
+	graphEnvFiles  []string
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Draw a workflow as a DOT or Mermaid diagram",
+	Long: `Draw a workflow's steps and edges as a Graphviz DOT or Mermaid diagram.
+
+With --execution, every step is coloured by its status in that execution. The workflow
+is then taken from the execution unless --file is also given. ${env:NAME} references in
+the file are interpolated as by sire run, so variables without a default must be set or
+read from --env-file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if graphFile == "" && graphExecution == "" {
+			fmt.Println("Error: either --file or --execution is required")
+			os.Exit(1)
+		}
+
+		var execution *core.Execution
+		if graphExecution != "" {
+			store, err := storage.NewBoltDBStore(dbPath)
+			if err != nil {
+				fmt.Printf("Error initializing database: %v\n", err)
+				os.Exit(1)
+			}
+			execution, err = store.LoadExecution(graphExecution)
+			if closeErr := store.Close(); closeErr != nil {
+				fmt.Printf("Error closing database: %v\n", closeErr)
+			}
+			if err != nil {
+				fmt.Printf("Error loading execution %s: %v\n", graphExecution, err)
+				os.Exit(1)
+			}
+		}
+
+		var workflow *core.Workflow
+		switch {
+		case graphFile != "":
+			env, err := loadEnv(graphEnvFiles)
+			if err != nil {
+				fmt.Printf("Error reading env file: %v\n", err)
+				os.Exit(1)
+			}
+			workflow, err = loader.Load(graphFile, loader.WithEnv(env))
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				os.Exit(1)
+			}
+		case execution.Workflow != nil:
+			workflow = execution.Workflow
+		default:
+			fmt.Printf("Error: execution %s has no workflow definition; pass --file\n", execution.ID)
+			os.Exit(1)
+		}
+
+		if err := graph.Render(os.Stdout, workflow, execution, graph.Format(graphFormat)); err != nil {
+			fmt.Printf("Error rendering graph: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	workflowCmd.AddCommand(graphCmd)
+	graphCmd.Flags().StringVarP(&graphFile, "file", "f", "", "Path to the workflow file (YAML or JSON)")
+	graphCmd.Flags().StringVar(&graphFormat, "format", string(graph.FormatDOT), "Diagram format: \"dot\" or \"mermaid\"")
+	graphCmd.Flags().StringVar(&graphExecution, "execution", "", "ID of an execution whose step statuses colour the nodes")
+	graphCmd.Flags().StringArrayVar(&graphEnvFiles, "env-file", nil, "Read ${env:NAME} variables from a .env file, taking precedence over the environment (repeatable)")
+	graphCmd.Flags().StringVarP(&dbPath, "db-path", "d", "sire.db", "Path to the BoltDB file for state persistence")
+}
@@ -3,50 +3,54 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"time" // New import for time.Now()
 
 	"github.com/google/uuid" // New import for generating UUIDs
 
 	"github.com/sire-run/sire/internal/core"
-	"github.com/sire-run/sire/internal/mcp/inprocess"
+	"github.com/sire-run/sire/internal/loader"
 	"github.com/sire-run/sire/internal/storage" // New import for storage
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
 var (
-	runFile   string
-	runInputs string
+	runFile           string
+	runInputs         string
+	runMaxParallelism int
+	runRetryMode      string
+	runEnvFiles       []string
 )
 
 var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Run a workflow",
 	Run: func(cmd *cobra.Command, args []string) {
-		// 1. Read workflow file
-		absRunFile, err := filepath.Abs(runFile)
+		// 1. Read and parse workflow file, interpolating environment variables
+		env, err := loadEnv(runEnvFiles)
 		if err != nil {
-			fmt.Printf("Error resolving workflow file path: %v\n", err)
+			fmt.Printf("Error reading env file: %v\n", err)
 			os.Exit(1)
 		}
-		// Clean the path to remove any ../ or ./ components.
-		// Note: This does not prevent directory traversal if the initial path is outside
-		// the intended working directory. A more robust solution would involve
-		// checking if the cleaned path is within a trusted base directory.
-		cleanedRunFile := filepath.Clean(absRunFile)
-		data, err := os.ReadFile(cleanedRunFile)
+		workflow, err := loader.Load(runFile, loader.WithEnv(env))
 		if err != nil {
-			fmt.Printf("Error reading workflow file: %v\n", err)
+			fmt.Printf("%v\n", err)
 			os.Exit(1)
 		}
 
-		// 2. Parse workflow
-		var workflow core.Workflow
-		if err := yaml.Unmarshal(data, &workflow); err != nil {
-			fmt.Printf("Error parsing workflow file: %v\n", err)
+		// 2. Resolve sub-workflow references relative to the workflow file
+		registry, err := newWorkflowRegistry(runFile, workflow, env)
+		if err != nil {
+			fmt.Printf("Error registering workflow: %v\n", err)
+			os.Exit(1)
+		}
+
+		retryMode := core.RetryMode(runRetryMode)
+		if retryMode != core.RetryModeWait && retryMode != core.RetryModeHandoff {
+			fmt.Printf("Error: invalid --retry-mode %q (expected %s or %s)\n", runRetryMode, core.RetryModeWait, core.RetryModeHandoff)
 			os.Exit(1)
 		}
 
@@ -58,6 +62,11 @@ var runCmd = &cobra.Command{
 				os.Exit(1)
 			}
 		}
+		inputs, err = workflow.ResolveInputs(inputs)
+		if err != nil {
+			fmt.Printf("Error validating inputs: %v\n", err)
+			os.Exit(1)
+		}
 
 		// 4. Initialize storage
 		store, err := storage.NewBoltDBStore(dbPath)
@@ -76,11 +85,14 @@ var runCmd = &cobra.Command{
 		execution := &core.Execution{
 			ID:         executionID,
 			WorkflowID: workflow.ID,
-			Workflow:   &workflow, // Store the workflow definition
+			Workflow:   workflow, // Store the workflow definition
+			Env:        env.Resolved(),
 			Status:     core.ExecutionStatusRunning,
 			StepStates: make(map[string]*core.StepState),
 			CreatedAt:  time.Now(),
 			UpdatedAt:  time.Now(),
+
+			MaxParallelism: runMaxParallelism,
 		}
 		if err := store.SaveExecution(execution); err != nil {
 			fmt.Printf("Error saving new execution: %v\n", err)
@@ -88,20 +100,34 @@ var runCmd = &cobra.Command{
 		}
 
 		// 6. Execute workflow
-		dispatcher := inprocess.NewInProcessDispatcher()
+		dispatcher := newDispatcher()
 		// The engine will now take the store as well (part of S9.2.2)
 		// For now, we'll just pass the dispatcher. The engine will be refactored later.
-		engine := core.NewEngine(dispatcher, store) // Pass store to NewEngine
+		engine := core.NewEngine(dispatcher, store, core.WithWorkflowResolver(registry), core.WithRetryMode(retryMode)) // Pass store to NewEngine
+
+		// Interrupting a run waiting for a retry leaves the execution in the store for `sire agent`.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
 
 		// Pass the initial execution to the engine
-		execution, err = engine.Execute(context.Background(), execution, &workflow, inputs) // Pass execution object
+		execution, err = engine.Execute(ctx, execution, workflow, inputs) // Pass execution object
+		if errors.Is(err, core.ErrExecutionWaiting) {
+			// Parked executions are resumed by the agent once signalled or once their timers fire.
+			fmt.Fprintf(os.Stderr, "Execution %s is waiting; run `sire agent` to resume it once its timers fire or its signal steps are signalled with `sire execution signal %s <step-id>`\n", execution.ID, execution.ID)
+			return
+		}
 		if err != nil {
 			fmt.Printf("Error executing workflow: %v\n", err)
+			// Executions handed off for a retry or interrupted are left for the agent.
+			if execution.Status == core.ExecutionStatusRunning || execution.Status == core.ExecutionStatusRetrying {
+				fmt.Fprintf(os.Stderr, "Execution %s is still %s; run `sire agent` to resume it\n", execution.ID, execution.Status)
+			}
 			os.Exit(1)
 		}
 
-		// 7. Print output
-		outputJSON, err := json.MarshalIndent(execution, "", "  ")
+		// 7. Print the workflow outputs; the full state is available via `sire execution status`
+		fmt.Fprintf(os.Stderr, "Execution %s %s\n", execution.ID, execution.Status)
+		outputJSON, err := json.MarshalIndent(execution.Outputs, "", "  ")
 		if err != nil {
 			fmt.Printf("Error marshaling execution output: %v\n", err)
 			os.Exit(1)
@@ -118,5 +144,8 @@ func init() {
 		os.Exit(1)
 	}
 	runCmd.Flags().StringVarP(&runInputs, "inputs", "i", "", "JSON string of inputs to the workflow")
+	runCmd.Flags().IntVar(&runMaxParallelism, "max-parallelism", 0, "Maximum number of steps to run concurrently (0 means unlimited)")
+	runCmd.Flags().StringVar(&runRetryMode, "retry-mode", string(core.RetryModeWait), "How to handle step retries: \"wait\" sleeps until they are due, \"handoff\" exits and leaves them to `sire agent`")
+	runCmd.Flags().StringArrayVar(&runEnvFiles, "env-file", nil, "Read ${env:NAME} variables from a .env file, taking precedence over the environment (repeatable)")
 	runCmd.Flags().StringVarP(&dbPath, "db-path", "d", "sire.db", "Path to the BoltDB file for state persistence") // New flag
 }
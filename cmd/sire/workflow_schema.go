@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sire-run/sire/internal/schema"
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of workflow files",
+	Long: `Print the JSON Schema of workflow files, generated from the workflow types.
+
+Point an editor's YAML language server at it for autocompletion, e.g. with a
+"# yaml-language-server: $schema=<path>" comment. The same schema is published as
+docs/workflow.schema.json for checking workflow files in CI.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := schema.Generate()
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(string(data))
+	},
+}
+
+func init() {
+	workflowCmd.AddCommand(schemaCmd)
+}
@@ -1,50 +1,111 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 
-	"github.com/sire-run/sire/internal/core"
+	"github.com/sire-run/sire/internal/loader"
+	"github.com/sire-run/sire/internal/mcp/inprocess"
+	"github.com/sire-run/sire/internal/validation"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
-var validateFile string
+var (
+	validateFile     string
+	validateFormat   string
+	validateEnvFiles []string
+)
 
 var validateCmd = &cobra.Command{
 	Use:   "validate",
 	Short: "Validate a workflow file",
+	Long: `Validate a workflow file without running it.
+
+The checks cover duplicate step IDs, unknown fields, edges to unknown steps, cycles,
+malformed or unregistered tool URIs, template and expression references to steps that
+have not run yet, invalid retry policies and matrices, and everything else checked
+before a workflow runs. The file may be YAML or JSON, as told by its extension.
+${env:NAME} references are interpolated as by sire run, so unset variables are
+reported too. Issues are reported with their line and column; --format json prints
+them for editors.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		absValidateFile, err := filepath.Abs(validateFile)
-		if err != nil {
-			fmt.Printf("Error resolving workflow file path: %v\n", err)
+		if validateFormat != "text" && validateFormat != "json" {
+			fmt.Printf("Error: invalid --format %q (expected text or json)\n", validateFormat)
 			os.Exit(1)
 		}
-		// Clean the path to remove any ../ or ./ components.
-		// Note: This does not prevent directory traversal if the initial path is outside
-		// the intended working directory. A more robust solution would involve
-		// checking if the cleaned path is within a trusted base directory.
-		cleanedValidateFile := filepath.Clean(absValidateFile)
-		data, err := os.ReadFile(cleanedValidateFile)
+		validator := validation.New(
+			validation.WithSchemes(newDispatcher().Schemes()...),
+			validation.WithLocalTools(inprocess.GetInProcessServer().ListRegisteredTools()...),
+		)
+		var issues []validation.Issue
+		env, err := loadEnv(validateEnvFiles)
 		if err != nil {
-			fmt.Printf("Error reading workflow file: %v\n", err)
+			fmt.Printf("Error reading env file: %v\n", err)
 			os.Exit(1)
 		}
+		doc, err := loader.LoadNode(validateFile, loader.WithEnv(env))
+		if err != nil {
+			issues = loadIssues(err)
+		} else {
+			issues = validator.ValidateNode(doc)
+		}
 
-		var workflow core.Workflow
-		if err := yaml.Unmarshal(data, &workflow); err != nil {
-			fmt.Printf("Error parsing workflow file: %v\n", err)
+		if validateFormat == "json" {
+			outputJSON, err := json.MarshalIndent(map[string]interface{}{
+				"file":   validateFile,
+				"valid":  len(issues) == 0,
+				"issues": issues,
+			}, "", "  ")
+			if err != nil {
+				fmt.Printf("Error marshaling issues: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(outputJSON))
+		} else {
+			for _, issue := range issues {
+				file := validateFile
+				if issue.File != "" {
+					file = issue.File
+				}
+				if issue.Line == 0 {
+					fmt.Printf("%s: %s\n", file, issue)
+					continue
+				}
+				fmt.Printf("%s:%s\n", file, issue)
+			}
+			if len(issues) == 0 {
+				fmt.Println("Workflow file is valid.")
+			}
+		}
+		if len(issues) > 0 {
 			os.Exit(1)
 		}
-
-		// Basic validation is done by unmarshalling. We can add more complex validation here later,
-		// like checking for circular dependencies, which is already handled by the engine's topological sort.
-
-		fmt.Println("Workflow file is valid.")
 	},
 }
 
+// loadIssues converts the errors of parsing a workflow file, expanding its step templates
+// or interpolating its environment variables into issues.
+func loadIssues(err error) []validation.Issue {
+	var errs []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	} else {
+		errs = []error{err}
+	}
+	issues := make([]validation.Issue, 0, len(errs))
+	for _, err := range errs {
+		var loaderErr *loader.Error
+		if errors.As(err, &loaderErr) {
+			issues = append(issues, validation.Issue{File: loaderErr.File, Line: loaderErr.Line, Column: loaderErr.Column, Message: loaderErr.Err.Error()})
+			continue
+		}
+		issues = append(issues, validation.Issue{Message: err.Error()})
+	}
+	return issues
+}
+
 func init() {
 	workflowCmd.AddCommand(validateCmd)
 	validateCmd.Flags().StringVarP(&validateFile, "file", "f", "", "Path to the workflow file (YAML or JSON)")
@@ -52,4 +113,6 @@ func init() {
 		fmt.Printf("Error marking flag as required: %v\n", err)
 		os.Exit(1)
 	}
+	validateCmd.Flags().StringArrayVar(&validateEnvFiles, "env-file", nil, "Read ${env:NAME} variables from a .env file, taking precedence over the environment (repeatable)")
+	validateCmd.Flags().StringVar(&validateFormat, "format", "text", "Output format: \"text\" or \"json\"")
 }
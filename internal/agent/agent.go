@@ -55,10 +55,20 @@ func (a *Agent) scanAndResume(ctx context.Context) {
 	}
 
 	for _, exec := range executions {
+		// Sub-workflow executions are resumed by the parent step that owns them.
+		if exec.ParentExecutionID != "" {
+			continue
+		}
+
 		// Check if the execution is actually ready for retry (NextAttempt time has passed)
 		// This check is also in the engine, but good to have here to avoid unnecessary processing
+		// Compensating and finalizing executions resume their rollback or hooks, which do
+		// not wait for retries. Waiting executions are checked below.
 		readyForRetry := true
 		for _, stepState := range exec.StepStates {
+			if exec.Status == core.ExecutionStatusCompensating || exec.Status == core.ExecutionStatusFinalizing || exec.Status == core.ExecutionStatusWaiting {
+				break
+			}
 			if stepState.Status == core.StepStatusRetrying && time.Now().Before(stepState.NextAttempt) {
 				readyForRetry = false
 				break
@@ -69,6 +79,12 @@ func (a *Agent) scanAndResume(ctx context.Context) {
 			continue
 		}
 
+		// Waiting executions stay parked until a step is signalled, times out, has its timer fire
+		// or is due for a retry.
+		if !core.Woken(a.store, exec, time.Now()) {
+			continue
+		}
+
 		log.Printf("Agent: Resuming execution %s (Workflow: %s)", exec.ID, exec.WorkflowID)
 
 		// Use the workflow definition stored in the execution object
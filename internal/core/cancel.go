@@ -0,0 +1,147 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrExecutionCancelled is returned by Execute for an execution that has been cancelled.
+var ErrExecutionCancelled = errors.New("execution cancelled")
+
+// ErrorCodeCancelled marks a step interrupted because its execution was cancelled.
+const ErrorCodeCancelled = "cancelled"
+
+// cancelPollInterval is how often a run checks the store for a cancellation made by another
+// process.
+const cancelPollInterval = time.Second
+
+// Cancel stops an execution for good. The execution is marked cancelled in the store, so
+// neither the agent nor a later Execute call resumes it. If it is running in this engine,
+// the contexts of its in-flight steps are cancelled as well; their partial state is kept
+// and they are marked cancelled. Child executions of sub-workflow steps are cancelled with
+// their parent.
+func (e *Engine) Cancel(id string) error {
+	e.mu.Lock()
+	r := e.runs[id]
+	e.mu.Unlock()
+
+	var childIDs []string
+	if r != nil {
+		r.mu.Lock()
+		if err := checkCancellable(r.execution); err != nil {
+			r.mu.Unlock()
+			return err
+		}
+		r.execution.Status = ExecutionStatusCancelled
+		_ = r.save() // Attempt to save state
+		childIDs = childExecutionIDs(r.execution)
+		r.mu.Unlock()
+		// Children are marked first, so that they record their interrupted steps as
+		// cancelled rather than failed once the parent's context is cancelled.
+		defer r.cancel()
+	} else {
+		if e.store == nil {
+			return fmt.Errorf("execution %s is not running and no store is configured", id)
+		}
+		execution, err := e.store.LoadExecution(id)
+		if err != nil {
+			return fmt.Errorf("failed to load execution %s: %w", id, err)
+		}
+		if err := checkCancellable(execution); err != nil {
+			return err
+		}
+		execution.Status = ExecutionStatusCancelled
+		markStepsCancelled(execution)
+		execution.UpdatedAt = time.Now()
+		if err := e.store.SaveExecution(execution); err != nil {
+			return fmt.Errorf("failed to save execution %s: %w", id, err)
+		}
+		childIDs = childExecutionIDs(execution)
+	}
+
+	// Children running in this engine stop with their parent's context; this also
+	// covers children that are only in the store. Finished children are left alone.
+	for _, childID := range childIDs {
+		_ = e.Cancel(childID)
+	}
+	return nil
+}
+
+// checkCancelled reports whether the execution has been cancelled, either in this process or,
+// through the store, by another one; in the latter case the run's context is cancelled too.
+// The store is read at most once per cancelPollInterval, so a cancellation by another process
+// is noticed within about that long. Only stores shared between processes can carry one:
+// a BoltDB file is locked by the process that opened it, so with BoltDB an execution can
+// only be cancelled once the process running it has let go of the database. The caller
+// must hold r.mu.
+func (r *executionRun) checkCancelled() bool {
+	if r.execution.Status == ExecutionStatusCancelled {
+		return true
+	}
+	if r.engine.store == nil || time.Since(r.polled) < cancelPollInterval {
+		return false
+	}
+	r.polled = time.Now()
+	stored, err := r.engine.store.LoadExecution(r.execution.ID)
+	if err != nil || stored.Status != ExecutionStatusCancelled {
+		return false
+	}
+	r.execution.Status = ExecutionStatusCancelled
+	r.cancel()
+	return true
+}
+
+// cancelled reports whether the execution has been cancelled in this process.
+func (r *executionRun) cancelled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.execution.Status == ExecutionStatusCancelled
+}
+
+// finishCancelled records the final state of a cancelled run and returns its error. The caller must hold r.mu.
+func (r *executionRun) finishCancelled() error {
+	markStepsCancelled(r.execution)
+	_ = r.save() // Attempt to save state
+	return fmt.Errorf("execution %s: %w", r.execution.ID, ErrExecutionCancelled)
+}
+
+// checkCancellable returns an error if the execution has already finished or been cancelled.
+func checkCancellable(execution *Execution) error {
+	switch execution.Status {
+	case ExecutionStatusCompleted, ExecutionStatusCancelled:
+		return fmt.Errorf("execution %s is already %s", execution.ID, execution.Status)
+	default:
+		return nil
+	}
+}
+
+// markStepsCancelled marks every step that has started but not finished as cancelled,
+// keeping its attempts, output, items and error.
+func markStepsCancelled(execution *Execution) {
+	for _, stepState := range execution.StepStates {
+		switch stepState.Status {
+		case StepStatusPending, StepStatusRunning, StepStatusRetrying, StepStatusWaiting:
+			stepState.Status = StepStatusCancelled
+		}
+	}
+}
+
+// childExecutionIDs returns the IDs of the child executions started by sub-workflow steps.
+func childExecutionIDs(execution *Execution) []string {
+	var ids []string
+	for _, stepState := range execution.StepStates {
+		if stepState.ChildExecutionID != "" {
+			ids = append(ids, stepState.ChildExecutionID)
+		}
+	}
+	return ids
+}
+
+// minTime returns the earlier of a and b.
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
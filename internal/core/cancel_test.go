@@ -0,0 +1,233 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// copyingStore keeps executions as JSON, like BoltDBStore, so that engines sharing it see
+// each other's writes only through the store, as separate processes would.
+type copyingStore struct {
+	MockStore
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (s *copyingStore) SaveExecution(execution *Execution) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored, ok := s.data[execution.ID]; ok && execution.Status != ExecutionStatusCancelled {
+		var current Execution
+		if err := json.Unmarshal(stored, &current); err != nil {
+			return err
+		}
+		if current.Status == ExecutionStatusCancelled {
+			return fmt.Errorf("execution %s: %w", execution.ID, ErrExecutionCancelled)
+		}
+	}
+	data, err := json.Marshal(execution)
+	if err != nil {
+		return err
+	}
+	if s.data == nil {
+		s.data = make(map[string][]byte)
+	}
+	s.data[execution.ID] = data
+	return nil
+}
+
+func (s *copyingStore) LoadExecution(id string) (*Execution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.data[id]
+	if !ok {
+		return nil, fmt.Errorf("execution with ID %s not found", id)
+	}
+	var execution Execution
+	if err := json.Unmarshal(data, &execution); err != nil {
+		return nil, err
+	}
+	return &execution, nil
+}
+
+func TestEngine_Cancel_RunningExecution(t *testing.T) {
+	started := make(chan struct{})
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			if tool == "sire:local/test.fast" {
+				return map[string]interface{}{"done": true}, nil
+			}
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	store := &MockStore{}
+	engine := NewEngine(dispatcher, store)
+
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "fast", Tool: "sire:local/test.fast"},
+			{ID: "slow", Tool: "sire:local/test.slow", Retry: &RetryPolicy{MaxAttempts: 3}},
+			{ID: "after", Tool: "sire:local/test.fast"},
+		},
+		Edges: []Edge{{From: "fast", To: "slow"}, {From: "slow", To: "after"}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := engine.Execute(context.Background(), execution, workflow, nil)
+		done <- err
+	}()
+
+	<-started
+	if err := engine.Cancel(execution.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrExecutionCancelled) {
+			t.Fatalf("expected ErrExecutionCancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected Execute to return after Cancel")
+	}
+
+	if execution.Status != ExecutionStatusCancelled {
+		t.Errorf("expected status %q, got %q", ExecutionStatusCancelled, execution.Status)
+	}
+	slow := execution.StepStates["slow"]
+	if slow.Status != StepStatusCancelled || slow.ErrorCode != ErrorCodeCancelled || slow.Attempts != 1 {
+		t.Errorf("expected the in-flight step to be cancelled after 1 attempt, got %+v", slow)
+	}
+	if fast := execution.StepStates["fast"]; fast.Status != StepStatusCompleted || fast.Output["done"] != true {
+		t.Errorf("expected completed steps to keep their state, got %+v", fast)
+	}
+	if _, ok := execution.StepStates["after"]; ok {
+		t.Errorf("expected no step to start after the cancellation")
+	}
+
+	// A cancelled execution is neither resumed nor cancelled again.
+	if _, err := engine.Execute(context.Background(), execution, workflow, nil); !errors.Is(err, ErrExecutionCancelled) {
+		t.Errorf("expected ErrExecutionCancelled on resume, got %v", err)
+	}
+	if err := engine.Cancel(execution.ID); err == nil {
+		t.Errorf("expected an error cancelling a cancelled execution, got none")
+	}
+	pending, _ := store.ListPendingExecutions()
+	if len(pending) != 0 {
+		t.Errorf("expected no pending executions, got %d", len(pending))
+	}
+}
+
+func TestEngine_Cancel_StoredExecution(t *testing.T) {
+	store := &MockStore{}
+	engine := NewEngine(&MockDispatcher{}, store)
+
+	retrying := &Execution{
+		ID:     "retrying",
+		Status: ExecutionStatusRunning,
+		StepStates: map[string]*StepState{
+			"done":  {Status: StepStatusCompleted, Output: map[string]interface{}{"ok": true}},
+			"flaky": {Status: StepStatusRetrying, Attempts: 2, Error: "service unavailable", NextAttempt: time.Now().Add(time.Hour)},
+			"sub":   {Status: StepStatusRetrying, ChildExecutionID: "retrying.sub"},
+		},
+	}
+	child := &Execution{
+		ID:                "retrying.sub",
+		Status:            ExecutionStatusRunning,
+		ParentExecutionID: "retrying",
+		StepStates:        map[string]*StepState{"send": {Status: StepStatusRetrying}},
+	}
+	completed := &Execution{ID: "completed", Status: ExecutionStatusCompleted}
+	for _, execution := range []*Execution{retrying, child, completed} {
+		_ = store.SaveExecution(execution)
+	}
+
+	if err := engine.Cancel("retrying"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if retrying.Status != ExecutionStatusCancelled {
+		t.Errorf("expected status %q, got %q", ExecutionStatusCancelled, retrying.Status)
+	}
+	flaky := retrying.StepStates["flaky"]
+	if flaky.Status != StepStatusCancelled || flaky.Attempts != 2 || flaky.Error != "service unavailable" {
+		t.Errorf("expected the retrying step to be cancelled with its partial state, got %+v", flaky)
+	}
+	if got := retrying.StepStates["done"].Status; got != StepStatusCompleted {
+		t.Errorf("expected completed steps to stay completed, got %s", got)
+	}
+	if child.Status != ExecutionStatusCancelled {
+		t.Errorf("expected the child execution to be cancelled, got %s", child.Status)
+	}
+
+	if err := engine.Cancel("completed"); err == nil {
+		t.Errorf("expected an error cancelling a completed execution, got none")
+	}
+	if err := engine.Cancel("missing"); err == nil {
+		t.Errorf("expected an error cancelling an unknown execution, got none")
+	}
+}
+
+func TestEngine_Cancel_ByAnotherEngine(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var mu sync.Mutex
+	var ran []string
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			mu.Lock()
+			ran = append(ran, tool)
+			mu.Unlock()
+			if tool == "sire:local/a.b" {
+				close(started)
+				<-release // The tool finishes despite the cancellation
+			}
+			return map[string]interface{}{}, nil
+		},
+	}
+	store := &copyingStore{}
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "setup", Tool: "sire:local/setup.run"}, // Stores the execution before a starts
+			{ID: "a", Tool: "sire:local/a.b"},
+			{ID: "b", Tool: "sire:local/b.c"},
+		},
+		Edges: []Edge{{From: "setup", To: "a"}, {From: "a", To: "b"}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewEngine(dispatcher, store).Execute(context.Background(), execution, workflow, nil)
+		done <- err
+	}()
+
+	<-started
+	// The other engine only sees the execution in the store, as `sire execution cancel` would.
+	if err := NewEngine(dispatcher, store).Cancel(execution.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(release)
+	if err := <-done; !errors.Is(err, ErrExecutionCancelled) {
+		t.Fatalf("expected ErrExecutionCancelled, got %v", err)
+	}
+
+	stored, err := store.LoadExecution(execution.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored.Status != ExecutionStatusCancelled || execution.Status != ExecutionStatusCancelled {
+		t.Errorf("expected the execution to stay cancelled, got %s in memory and %s in the store", execution.Status, stored.Status)
+	}
+	if len(ran) != 2 {
+		t.Errorf("expected no step to start after the cancellation, ran %v", ran)
+	}
+}
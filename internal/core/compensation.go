@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// compensate rolls back a failed execution: the compensations of its completed steps run
+// one at a time, in reverse completion order. Progress is kept in StepState.Compensation
+// while the execution is compensating, so a resumed rollback skips the compensations that
+// already ran. The execution ends up failed; the returned error wraps cause and any
+// compensation failure.
+func (r *executionRun) compensate(ctx context.Context, cause error) error {
+	r.mu.Lock()
+	r.execution.Status = ExecutionStatusCompensating
+	var pending []string
+	for i := len(r.execution.CompletionOrder) - 1; i >= 0; i-- {
+		stepID := r.execution.CompletionOrder[i]
+		stepState := r.execution.StepStates[stepID]
+		if r.steps[stepID].Compensate == nil || stepState == nil {
+			continue
+		}
+		if stepState.Compensation == nil {
+			stepState.Compensation = &CompensationState{Status: StepStatusPending}
+		}
+		if stepState.Compensation.Status != StepStatusCompleted {
+			pending = append(pending, stepID)
+		}
+	}
+	_ = r.save() // Attempt to save state
+	r.mu.Unlock()
+
+	for _, stepID := range pending {
+		if err := r.compensateStep(ctx, r.steps[stepID]); err != nil {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.checkCancelled() {
+				return r.finishCancelled()
+			}
+			r.execution.Status = ExecutionStatusFailed
+			_ = r.save() // Attempt to save state
+			return errors.Join(cause, fmt.Errorf("compensation of step %s failed: %w", stepID, err))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.checkCancelled() {
+		return r.finishCancelled()
+	}
+	r.execution.Status = ExecutionStatusFailed
+	_ = r.save() // Attempt to save state
+	return cause
+}
+
+// compensateStep dispatches the compensation of a single completed step and records its outcome.
+func (r *executionRun) compensateStep(ctx context.Context, step Step) error {
+	r.mu.Lock()
+	if r.checkCancelled() {
+		r.mu.Unlock()
+		return ErrExecutionCancelled
+	}
+	state := r.execution.StepStates[step.ID].Compensation
+	params, err := ResolveParams(step.Compensate.Params, r.stepData(step))
+	if err != nil {
+		state.Status = StepStatusFailed
+		state.Error = err.Error()
+		_ = r.save() // Attempt to save state
+		r.mu.Unlock()
+		return err
+	}
+	state.Attempts++
+	state.Status = StepStatusRunning
+	_ = r.save() // Attempt to save state
+	r.mu.Unlock()
+
+	// The compensation is bounded by the step's own timeout.
+	output, err := r.dispatch(ctx, Step{ID: step.ID, Tool: step.Compensate.Tool, Timeout: step.Timeout}, params)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		state.Status = StepStatusFailed
+		state.Error = err.Error()
+		_ = r.save() // Attempt to save state
+		return err
+	}
+	state.Status = StepStatusCompleted
+	state.Output = output
+	state.Error = ""
+	return r.save()
+}
+
+// needsCompensation reports whether the execution has failed with completed steps to undo.
+func (r *executionRun) needsCompensation() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.execution.Status != ExecutionStatusFailed {
+		return false
+	}
+	for _, stepID := range r.execution.CompletionOrder {
+		if r.steps[stepID].Compensate != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// compensationStarted reports whether the rollback of the execution has begun.
+func compensationStarted(execution *Execution) bool {
+	for _, stepState := range execution.StepStates {
+		if stepState.Compensation != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// failureError describes why an execution failed, from the errors of its failed steps.
+func failureError(execution *Execution) error {
+	var failures []string
+	for stepID, stepState := range execution.StepStates {
+		if stepState.Status == StepStatusFailed {
+			failures = append(failures, fmt.Sprintf("step %s: %s", stepID, stepState.Error))
+		}
+	}
+	if len(failures) == 0 {
+		return fmt.Errorf("execution %s failed", execution.ID)
+	}
+	sort.Strings(failures)
+	return fmt.Errorf("execution %s failed: %s", execution.ID, strings.Join(failures, "; "))
+}
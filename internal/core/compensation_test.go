@@ -0,0 +1,152 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEngine_Execute_Compensation(t *testing.T) {
+	var calls []string
+	failRefund := true
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			switch tool {
+			case "sire:local/record.create":
+				calls = append(calls, "create")
+				return map[string]interface{}{"record_id": "r-1"}, nil
+			case "sire:local/card.charge":
+				calls = append(calls, "charge")
+				return map[string]interface{}{"charge_id": "c-1"}, nil
+			case "sire:local/order.ship":
+				calls = append(calls, "ship")
+				return nil, errors.New("warehouse unavailable")
+			case "sire:local/record.delete":
+				calls = append(calls, "delete "+params["id"].(string))
+				return map[string]interface{}{"deleted": true}, nil
+			case "sire:local/card.refund":
+				calls = append(calls, "refund "+params["charge"].(string))
+				if failRefund {
+					return nil, errors.New("payment gateway timeout")
+				}
+				return map[string]interface{}{"refunded": true}, nil
+			default:
+				return nil, fmt.Errorf("unknown tool: %s", tool)
+			}
+		},
+	}
+	store := &MockStore{}
+	engine := NewEngine(dispatcher, store)
+
+	workflow := &Workflow{
+		ID: "orders",
+		Steps: []Step{
+			{
+				ID:         "create",
+				Tool:       "sire:local/record.create",
+				Compensate: &ToolCall{Tool: "sire:local/record.delete", Params: map[string]interface{}{"id": "{{ .create.output.record_id }}"}},
+			},
+			{
+				ID:         "charge",
+				Tool:       "sire:local/card.charge",
+				Compensate: &ToolCall{Tool: "sire:local/card.refund", Params: map[string]interface{}{"charge": "{{ .steps.charge.output.charge_id }}"}},
+			},
+			{ID: "ship", Tool: "sire:local/order.ship"},
+		},
+		Edges: []Edge{{From: "create", To: "charge"}, {From: "charge", To: "ship"}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	// The refund fails, so the rollback stops before deleting the record.
+	_, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil || !strings.Contains(err.Error(), "warehouse unavailable") || !strings.Contains(err.Error(), "compensation of step charge failed") {
+		t.Fatalf("expected the step and compensation errors, got %v", err)
+	}
+	if execution.Status != ExecutionStatusFailed {
+		t.Errorf("expected status %q, got %q", ExecutionStatusFailed, execution.Status)
+	}
+	if !reflect.DeepEqual(execution.CompletionOrder, []string{"create", "charge"}) {
+		t.Errorf("unexpected completion order: %v", execution.CompletionOrder)
+	}
+	if got := execution.StepStates["charge"].Compensation; got.Status != StepStatusFailed || got.Error != "payment gateway timeout" {
+		t.Errorf("expected a failed compensation for charge, got %+v", got)
+	}
+	if got := execution.StepStates["create"].Compensation; got.Status != StepStatusPending {
+		t.Errorf("expected the compensation of create to be pending, got %+v", got)
+	}
+
+	// Resuming resumes the rollback, not the forward path.
+	failRefund = false
+	_, err = engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil || !strings.Contains(err.Error(), "warehouse unavailable") {
+		t.Fatalf("expected the original failure, got %v", err)
+	}
+	want := []string{"create", "charge", "ship", "refund c-1", "refund c-1", "delete r-1"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected calls %v, got %v", want, calls)
+	}
+	for _, stepID := range []string{"create", "charge"} {
+		if got := execution.StepStates[stepID].Compensation; got.Status != StepStatusCompleted {
+			t.Errorf("expected the compensation of %s to complete, got %+v", stepID, got)
+		}
+	}
+	if got := execution.StepStates["charge"].Compensation.Attempts; got != 2 {
+		t.Errorf("expected 2 compensation attempts for charge, got %d", got)
+	}
+	if execution.Status != ExecutionStatusFailed {
+		t.Errorf("expected status %q, got %q", ExecutionStatusFailed, execution.Status)
+	}
+}
+
+func TestEngine_Execute_ResumesCompensating(t *testing.T) {
+	var calls []string
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			calls = append(calls, tool)
+			return map[string]interface{}{}, nil
+		},
+	}
+	store := &MockStore{}
+	engine := NewEngine(dispatcher, store)
+
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "a", Tool: "sire:local/test.a", Compensate: &ToolCall{Tool: "sire:local/test.undo_a"}},
+			{ID: "b", Tool: "sire:local/test.b", Compensate: &ToolCall{Tool: "sire:local/test.undo_b"}},
+			{ID: "c", Tool: "sire:local/test.c"},
+		},
+	}
+	// A crash happened after b's compensation completed.
+	execution := &Execution{
+		ID:              "test-execution",
+		WorkflowID:      workflow.ID,
+		Status:          ExecutionStatusCompensating,
+		CompletionOrder: []string{"a", "b"},
+		StepStates: map[string]*StepState{
+			"a": {Status: StepStatusCompleted, Compensation: &CompensationState{Status: StepStatusPending}},
+			"b": {Status: StepStatusCompleted, Compensation: &CompensationState{Status: StepStatusCompleted}},
+			"c": {Status: StepStatusFailed, Error: "boom"},
+		},
+	}
+	_ = store.SaveExecution(execution)
+
+	pending, _ := store.ListPendingExecutions()
+	if len(pending) != 1 {
+		t.Fatalf("expected the compensating execution to be pending, got %d", len(pending))
+	}
+
+	_, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil || !strings.Contains(err.Error(), "step c: boom") {
+		t.Fatalf("expected the original failure, got %v", err)
+	}
+	if !reflect.DeepEqual(calls, []string{"sire:local/test.undo_a"}) {
+		t.Errorf("expected only the pending compensation to run, got %v", calls)
+	}
+	if execution.Status != ExecutionStatusFailed {
+		t.Errorf("expected status %q, got %q", ExecutionStatusFailed, execution.Status)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"sort"
 )
 
 // Dispatcher is responsible for executing a tool.
@@ -28,6 +29,16 @@ func (m *DispatcherMux) Register(scheme string, dispatcher Dispatcher) {
 	m.dispatchers[scheme] = dispatcher
 }
 
+// Schemes returns the schemes with a registered dispatcher, sorted.
+func (m *DispatcherMux) Schemes() []string {
+	schemes := make([]string, 0, len(m.dispatchers))
+	for scheme := range m.dispatchers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
 // Dispatch dispatches a tool execution to the appropriate dispatcher.
 func (m *DispatcherMux) Dispatch(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
 	u, err := url.Parse(tool)
@@ -2,47 +2,128 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time" // New import
 )
 
 // Store defines the interface for storing and retrieving workflow executions.
 // This is a copy from internal/storage/storage.go to avoid circular dependency.
 // In a real project, this would be in a shared package or passed as an interface.
+//
+// SaveExecution must not overwrite an execution stored as cancelled with one that is not, so
+// that a run cannot undo a cancellation made by another process; it returns an error
+// wrapping ErrExecutionCancelled instead, checked in the same write.
 type Store interface {
 	SaveExecution(execution *Execution) error
 	LoadExecution(id string) (*Execution, error)
 	ListPendingExecutions() ([]*Execution, error)
+	SaveSignal(executionID, stepID string, payload map[string]interface{}) error
+	LoadSignal(executionID, stepID string) (map[string]interface{}, bool, error)
 	// Add other necessary methods like DeleteExecution, etc.
 }
 
 // Engine is responsible for executing workflows.
 type Engine struct {
-	dispatcher Dispatcher
-	store      Store // New field for storage
+	dispatcher     Dispatcher
+	store          Store // New field for storage
+	maxParallelism int   // Default limit on concurrently running steps, 0 means unlimited
+	resolver       WorkflowResolver
+	random         func() float64 // Source of retry jitter, returns values in [0, 1)
+	retryMode      RetryMode
+
+	mu   sync.Mutex               // Guards runs
+	runs map[string]*executionRun // Executions currently running in this engine, by ID
+}
+
+// RetryMode controls what Execute does when a step is scheduled for a retry.
+type RetryMode string
+
+const (
+	// RetryModeHandoff returns from Execute as soon as a step has to wait for a retry,
+	// leaving the execution for a later call, typically by the agent. It is the default.
+	RetryModeHandoff RetryMode = "handoff"
+	// RetryModeWait makes Execute sleep until the next retry is due and carry on, so a
+	// single call runs the execution to completion or failure, unless it has to wait for a
+	// signal or a timer: those always park it, as in handoff mode.
+	RetryModeWait RetryMode = "wait"
+)
+
+// EngineOption configures optional Engine behavior.
+type EngineOption func(*Engine)
+
+// WithMaxParallelism limits how many steps of an execution may run at the same time.
+// Executions that set their own MaxParallelism take precedence. Zero means unlimited.
+func WithMaxParallelism(n int) EngineOption {
+	return func(e *Engine) {
+		e.maxParallelism = n
+	}
+}
+
+// WithWorkflowResolver sets the resolver used to look up the workflows run by sub-workflow steps.
+func WithWorkflowResolver(resolver WorkflowResolver) EngineOption {
+	return func(e *Engine) {
+		e.resolver = resolver
+	}
+}
+
+// WithRandom sets the source of retry jitter. random must return values in [0, 1) and be
+// safe for concurrent use.
+func WithRandom(random func() float64) EngineOption {
+	return func(e *Engine) {
+		e.random = random
+	}
+}
+
+// WithRetryMode sets how Execute handles steps waiting for a retry.
+func WithRetryMode(mode RetryMode) EngineOption {
+	return func(e *Engine) {
+		e.retryMode = mode
+	}
 }
 
 // NewEngine creates a new execution engine.
-func NewEngine(dispatcher Dispatcher, store Store) *Engine {
-	return &Engine{dispatcher: dispatcher, store: store}
+func NewEngine(dispatcher Dispatcher, store Store, opts ...EngineOption) *Engine {
+	e := &Engine{dispatcher: dispatcher, store: store, random: defaultRandom, retryMode: RetryModeHandoff, runs: make(map[string]*executionRun)}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
 // Execute executes a workflow.
 // It now takes an existing execution object.
+// Steps run in waves: every step reported ready by GetExecutableSteps is dispatched
+// concurrently, and the next wave starts once the whole wave has finished.
 func (e *Engine) Execute(ctx context.Context, execution *Execution, workflow *Workflow, inputs map[string]interface{}) (*Execution, error) {
 	// No longer creating a new execution here, it's passed in.
+	if execution.Status == ExecutionStatusCancelled {
+		return execution, fmt.Errorf("execution %s: %w", execution.ID, ErrExecutionCancelled)
+	}
+	// A failed execution whose rollback has started never goes forward again; resuming it
+	// resumes the rollback.
+	rollback := execution.Status == ExecutionStatusCompensating ||
+		(execution.Status == ExecutionStatusFailed && compensationStarted(execution))
+	// A finalizing execution only has its hooks left to run.
+	finalizing := execution.Status == ExecutionStatusFinalizing
+
 	// Ensure initial status is running if it's a new execution or resuming
-	if execution.Status == "" { // Or some other initial state check
+	if !rollback && !finalizing && (execution.Status == "" || execution.Status == ExecutionStatusFailed || execution.Status == ExecutionStatusWaiting) {
 		execution.Status = ExecutionStatusRunning
 	}
+	if execution.StepStates == nil {
+		execution.StepStates = make(map[string]*StepState)
+	}
 
 	steps := make(map[string]Step)
 	for _, step := range workflow.Steps {
 		steps[step.ID] = step
 	}
 
-	sortedSteps, err := topologicalSort(steps, workflow.Edges)
-	if err != nil {
+	if _, err := workflow.TopologicalOrder(); err != nil {
 		execution.Status = ExecutionStatusFailed // Mark as failed if topological sort fails
 		if e.store != nil {
 			_ = e.store.SaveExecution(execution) // Attempt to save state
@@ -50,150 +131,623 @@ func (e *Engine) Execute(ctx context.Context, execution *Execution, workflow *Wo
 		return execution, fmt.Errorf("workflow topological sort failed: %w", err)
 	}
 
-	// Load stepOutputs from execution.StepStates if resuming
-	stepOutputs := make(map[string]map[string]interface{})
-	for stepID, stepState := range execution.StepStates {
-		if stepState.Status == StepStatusCompleted {
-			stepOutputs[stepID] = stepState.Output
+	if err := validateDefinition(workflow); err != nil {
+		execution.Status = ExecutionStatusFailed
+		if e.store != nil {
+			_ = e.store.SaveExecution(execution) // Attempt to save state
 		}
+		return execution, err
 	}
 
-	for _, stepID := range sortedSteps {
-		step := steps[stepID]
+	// The execution deadline is fixed on the first run.
+	if timeout, _ := parseTimeout(workflow.Timeout); timeout > 0 && execution.Deadline.IsZero() {
+		execution.Deadline = time.Now().Add(timeout)
+	}
 
-		// Get current step state or create a new one
-		stepState, ok := execution.StepStates[stepID]
-		if !ok {
-			stepState = &StepState{
-				Status: StepStatusPending,
+	// Inputs are checked against the workflow's declarations and recorded on the execution,
+	// so that a resume without inputs sees the original values.
+	if inputs != nil || execution.Inputs == nil {
+		resolved, err := workflow.ResolveInputs(inputs)
+		if err != nil {
+			execution.Status = ExecutionStatusFailed
+			if e.store != nil {
+				_ = e.store.SaveExecution(execution) // Attempt to save state
 			}
-			execution.StepStates[stepID] = stepState
+			return execution, err
 		}
+		execution.Inputs = resolved
+	}
 
-		// If step is already completed, skip it
-		if stepState.Status == StepStatusCompleted {
-			continue
+	// Steps that failed or were interrupted mid-dispatch in a previous run are queued again,
+	// except for tolerated failures, which are final.
+	if !rollback && !finalizing {
+		// Hooks run again for the outcome of this attempt.
+		for _, hook := range workflow.hooks() {
+			delete(execution.StepStates, hook.ID)
 		}
-
-		// If step is retrying and NextAttempt is in the future, skip it for now
-		if stepState.Status == StepStatusRetrying && time.Now().Before(stepState.NextAttempt) {
-			continue
+		for stepID, stepState := range execution.StepStates {
+			if stepState.Status == StepStatusRunning || (stepState.Status == StepStatusFailed && !isSettled(steps[stepID], stepState)) {
+				stepState.Status = StepStatusPending
+			}
 		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	r := &executionRun{
+		engine:    e,
+		execution: execution,
+		workflow:  workflow,
+		steps:     steps,
+		inputs:    execution.Inputs,
+		cancel:    cancel,
+	}
 
-		stepInputs := make(map[string]interface{})
-		// Start with the initial inputs to the workflow
-		for k, v := range inputs {
-			stepInputs[k] = v
+	// Running executions are tracked so that Cancel can reach their in-flight steps.
+	e.mu.Lock()
+	e.runs[execution.ID] = r
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		if e.runs[execution.ID] == r {
+			delete(e.runs, execution.ID)
 		}
-		// Add parameters defined in the step itself
-		for k, v := range step.Params {
-			stepInputs[k] = v
+		e.mu.Unlock()
+	}()
+
+	switch {
+	case finalizing && execution.Outcome == ExecutionStatusCompleted:
+		return r.execution, r.finish(ctx, nil)
+	case finalizing:
+		return r.execution, r.finish(ctx, failureError(execution))
+	case rollback:
+		return r.execution, r.finish(ctx, r.compensate(ctx, failureError(execution)))
+	}
+
+	// The execution deadline bounds the forward path only; compensations and hooks still run after it.
+	runCtx := ctx
+	if !execution.Deadline.IsZero() {
+		var cancelRun context.CancelFunc
+		runCtx, cancelRun = context.WithDeadline(ctx, execution.Deadline)
+		defer cancelRun()
+	}
+	_, err := r.run(runCtx)
+	if err != nil && r.needsCompensation() {
+		err = r.compensate(ctx, err)
+	}
+	return r.execution, r.finish(ctx, err)
+}
+
+// executionRun holds the state of a single call to Engine.Execute.
+// mu guards the execution and all of its step states, which are shared by the
+// goroutines of a wave.
+type executionRun struct {
+	engine    *Engine
+	workflow  *Workflow
+	steps     map[string]Step
+	inputs    map[string]interface{}
+	cancel    context.CancelFunc // Cancels the contexts of all in-flight steps
+	mu        sync.Mutex
+	execution *Execution
+	polled    time.Time // When checkCancelled last read the execution from the store
+}
+
+func (r *executionRun) run(ctx context.Context) (*Execution, error) {
+	wait := r.engine.retryMode == RetryModeWait
+	for {
+		r.mu.Lock()
+		if r.checkCancelled() {
+			defer r.mu.Unlock()
+			return r.execution, r.finishCancelled()
 		}
-		// Add outputs from parent steps
-		for _, edge := range workflow.Edges {
-			if edge.To == stepID {
-				if parentOutput, ok := stepOutputs[edge.From]; ok {
-					for k, v := range parentOutput {
-						stepInputs[k] = v
-					}
+		ready := append(GetExecutableSteps(r.workflow, r.execution.StepStates), r.wokenSteps(time.Now())...)
+		// Only retries are waited for in-process; timers always park the execution.
+		next := nextAttempt(r.execution)
+		r.mu.Unlock()
+
+		if len(ready) == 0 {
+			if next.IsZero() {
+				break
+			}
+			if r.pastDeadline(time.Now()) {
+				if err := r.expireRetries(ctx); err != nil {
+					return r.execution, err
 				}
+				continue
+			}
+			if !wait {
+				break
+			}
+			// Wake up periodically to notice cancellations made through the store, and at
+			// the execution deadline at the latest.
+			wake := minTime(next, time.Now().Add(cancelPollInterval))
+			if !r.execution.Deadline.IsZero() {
+				wake = minTime(wake, r.execution.Deadline)
+			}
+			if err := sleepUntil(ctx, wake); err != nil && !r.cancelled() && !r.pastDeadline(time.Now()) {
+				return r.execution, fmt.Errorf("stopped waiting to retry execution %s: %w", r.execution.ID, err)
+			}
+			continue
+		}
+
+		if err := r.runWave(ctx, ready); err != nil && !r.cancelled() {
+			// In wait mode only a failed execution or a cancelled context ends the run;
+			// steps scheduled for a retry are picked up by a later wave.
+			r.mu.Lock()
+			failed := r.execution.Status == ExecutionStatusFailed
+			r.mu.Unlock()
+			if !wait || failed || ctx.Err() != nil {
+				return r.execution, err
 			}
 		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.checkCancelled() {
+		return r.execution, r.finishCancelled()
+	}
 
-		// Increment attempt count
-		stepState.Attempts++
-		stepState.Status = StepStatusRunning // Mark as running before dispatch
+	// Steps waiting for a signal or a timer park the execution until the agent finds them
+	// woken.
+	if r.parkWaiting() {
+		return r.execution, fmt.Errorf("execution %s: %w", r.execution.ID, ErrExecutionWaiting)
+	}
 
-		output, err := e.dispatcher.Dispatch(ctx, step.Tool, stepInputs)
+	var waiting []string
+	for _, step := range r.workflow.Steps {
+		stepState, ok := r.execution.StepStates[step.ID]
+		if !ok || !isSettled(step, stepState) {
+			waiting = append(waiting, step.ID)
+		}
+	}
+	if len(waiting) > 0 {
+		if err := r.save(); err != nil {
+			return r.execution, err
+		}
+		return r.execution, fmt.Errorf("execution %s has steps that are not ready to run: %v", r.execution.ID, waiting)
+	}
+
+	outputs, err := evalOutputs(r.workflow, r.execution, buildTemplateData(r.execution, r.workflow, r.inputs))
+	if err != nil {
+		r.execution.Status = ExecutionStatusFailed
+		_ = r.save() // Attempt to save state
+		return r.execution, fmt.Errorf("failed to evaluate workflow outputs: %w", err)
+	}
+	// The execution is marked completed by finish, once its hooks have run.
+	r.execution.Outputs = outputs
+	_ = r.save() // Attempt to save state
+
+	return r.execution, nil
+}
+
+// runWave dispatches the given steps concurrently, honoring the parallelism limit,
+// and returns the first step error in wave order.
+func (r *executionRun) runWave(ctx context.Context, ready []string) error {
+	limit := r.execution.MaxParallelism
+	if limit <= 0 {
+		limit = r.engine.maxParallelism
+	}
+	if limit <= 0 || limit > len(ready) {
+		limit = len(ready)
+	}
+
+	sem := make(chan struct{}, limit)
+	errs := make([]error, len(ready))
+	var wg sync.WaitGroup
+	for i, stepID := range ready {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			errs[i] = r.runStep(ctx, r.steps[stepID])
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
 		if err != nil {
-			stepState.Error = err.Error()
-			if step.Retry != nil && stepState.Attempts < step.Retry.MaxAttempts {
-				// Calculate next attempt time based on configurable backoff policy
-				var backoffDuration time.Duration
-				switch step.Retry.Backoff {
-				case "exponential":
-					// Simple exponential backoff: base * 2^(attempts-1)
-					baseDuration := 1 * time.Second // Default base
-					// For simplicity, let's use baseDuration * Attempts for now
-					backoffDuration = baseDuration * time.Duration(stepState.Attempts)
-				default:
-					// Default to a fixed backoff if not specified or unknown
-					backoffDuration = 5 * time.Second
-				}
-				stepState.NextAttempt = time.Now().Add(backoffDuration)
-				stepState.Status = StepStatusRetrying
-			} else {
-				stepState.Status = StepStatusFailed
-				execution.Status = ExecutionStatusFailed // Mark overall execution as failed
-			}
-			if e.store != nil {
-				_ = e.store.SaveExecution(execution) // Attempt to save state
+			return err
+		}
+	}
+	return nil
+}
+
+// runStep dispatches a single step and records its outcome in the execution.
+func (r *executionRun) runStep(ctx context.Context, step Step) error {
+	r.mu.Lock()
+	if r.execution.Status == ExecutionStatusCancelled {
+		r.mu.Unlock()
+		return nil
+	}
+	// Get current step state or create a new one
+	stepState, ok := r.execution.StepStates[step.ID]
+	if !ok {
+		stepState = &StepState{
+			Status: StepStatusPending,
+		}
+		r.execution.StepStates[step.ID] = stepState
+	}
+	skip, err := r.shouldSkip(step)
+	if err != nil {
+		r.failStep(stepState, err)
+		r.mu.Unlock()
+		return fmt.Errorf("error evaluating condition for step %s: %w", step.ID, err)
+	}
+	if skip {
+		stepState.Status = StepStatusSkipped
+		err := r.save()
+		r.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("failed to save execution state after step %s: %w", step.ID, err)
+		}
+		return nil
+	}
+
+	if step.Foreach != "" {
+		r.mu.Unlock()
+		return r.runForeach(ctx, step, stepState)
+	}
+	if step.Workflow != "" {
+		r.mu.Unlock()
+		return r.runSubWorkflow(ctx, step, stepState)
+	}
+	if step.Signal != nil {
+		r.mu.Unlock()
+		return r.runSignal(ctx, step, stepState)
+	}
+	if step.isTimer() {
+		r.mu.Unlock()
+		return r.runTimer(ctx, step, stepState)
+	}
+
+	stepInputs, err := r.stepInputs(step, r.stepData(step))
+	if err != nil {
+		// A template that cannot be resolved will not resolve on a retry either.
+		r.failStep(stepState, err)
+		r.mu.Unlock()
+		return fmt.Errorf("error resolving params for step %s: %w", step.ID, err)
+	}
+
+	// Increment attempt count
+	stepState.Attempts++
+	stepState.Status = StepStatusRunning // Mark as running before dispatch
+	r.mu.Unlock()
+
+	output, err := r.dispatch(ctx, step, stepInputs)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		return r.recordFailure(ctx, step, stepState, err)
+	}
+	return r.completeStep(step, stepState, output)
+}
+
+// dispatch hands a single tool invocation for step to the dispatcher. The attempt is
+// bounded by the step timeout and the execution deadline even if the dispatcher does
+// not honor its context.
+func (r *executionRun) dispatch(ctx context.Context, step Step, params map[string]interface{}) (map[string]interface{}, error) {
+	stepCtx, cancel := stepContext(ctx, step)
+	defer cancel()
+
+	type result struct {
+		output map[string]interface{}
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := r.engine.dispatcher.Dispatch(stepCtx, step.Tool, params)
+		done <- result{output: output, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil && stepCtx.Err() != nil {
+			return nil, fmt.Errorf("%w: %w", timeoutError(stepCtx, ctx, step), res.err)
+		}
+		return res.output, res.err
+	case <-stepCtx.Done():
+		return nil, timeoutError(stepCtx, ctx, step)
+	}
+}
+
+// completeStep records a successful step and persists the execution. The caller must hold r.mu.
+func (r *executionRun) completeStep(step Step, stepState *StepState, output map[string]interface{}) error {
+	stepState.Status = StepStatusCompleted
+	stepState.Output = output
+	r.execution.CompletionOrder = append(r.execution.CompletionOrder, step.ID)
+	stepState.Error = ""                // Clear error on success
+	stepState.NextAttempt = time.Time{} // No retry pending anymore
+	stepState.WakeAt = time.Time{}
+	stepState.ErrorCode = ""
+
+	// Save state after each step (S9.2.3)
+	if err := r.save(); err != nil {
+		return fmt.Errorf("failed to save execution state after step %s: %w", step.ID, err)
+	}
+	return nil
+}
+
+// recordFailure records a failed attempt of step, scheduling a retry if its policy allows
+// another attempt, and returns the step error. Once the step has failed for good, its
+// OnError policy applies: the failure may be tolerated, or the step's fallback dispatched,
+// during which r.mu is released. The caller must hold r.mu.
+func (r *executionRun) recordFailure(ctx context.Context, step Step, stepState *StepState, err error) error {
+	stepState.Error = err.Error()
+	stepState.ErrorCode = errorCode(err)
+	if r.checkCancelled() {
+		stepState.Status = StepStatusCancelled
+		stepState.ErrorCode = ErrorCodeCancelled
+		_ = r.save() // Attempt to save state
+		return fmt.Errorf("error executing step %s: %w", step.ID, err)
+	}
+	// Nothing can be retried once the execution deadline has passed.
+	policy := retryPolicyFor(r.workflow, step)
+	var next time.Time
+	if policy != nil && stepState.Attempts < policy.MaxAttempts && stepState.ErrorCode != ErrorCodeDeadlineExceeded && policy.retryable(err, stepState.ErrorCode) {
+		next = time.Now().Add(policy.delay(stepState.Attempts, r.engine.random))
+	}
+	// A retry due after the execution deadline could never run.
+	if !next.IsZero() && (r.execution.Deadline.IsZero() || next.Before(r.execution.Deadline)) {
+		stepState.NextAttempt = next
+		stepState.Status = StepStatusRetrying
+		_ = r.save() // Attempt to save state
+		return fmt.Errorf("error executing step %s: %w", step.ID, err)
+	}
+
+	stepState.Status = StepStatusFailed
+	stepState.NextAttempt = time.Time{}
+	switch step.OnError {
+	case OnErrorContinue:
+		// The failure is recorded, but dependants and the execution go on.
+		return r.save()
+	case OnErrorFallback:
+		fallbackErr := r.runFallback(ctx, step, stepState)
+		if fallbackErr == nil {
+			return nil
+		}
+		err = fmt.Errorf("%w (fallback failed: %w)", err, fallbackErr)
+		stepState.Error = err.Error()
+		if stepState.Status == StepStatusCancelled {
+			_ = r.save() // Attempt to save state
+			return fmt.Errorf("error executing step %s: %w", step.ID, err)
+		}
+	}
+	r.execution.Status = ExecutionStatusFailed // Mark overall execution as failed
+	_ = r.save()                               // Attempt to save state
+	return fmt.Errorf("error executing step %s: %w", step.ID, err)
+}
+
+// shouldSkip reports whether a step must be skipped, either because a dependency was
+// skipped and the step's SkipPolicy propagates skips, or because its `when` condition
+// is false. The caller must hold r.mu.
+func (r *executionRun) shouldSkip(step Step) (bool, error) {
+	if step.SkipPolicy != SkipPolicyIgnore {
+		for _, dep := range dependenciesOf(r.workflow, step.ID) {
+			if depState, ok := r.execution.StepStates[dep]; ok && depState.Status == StepStatusSkipped {
+				return true, nil
 			}
-			return execution, fmt.Errorf("error executing step %s: %w", stepID, err)
 		}
-		stepOutputs[stepID] = output
+	}
+	if step.When == "" {
+		return false, nil
+	}
+	run, err := evalCondition(step.When, r.stepData(step))
+	if err != nil {
+		return false, err
+	}
+	return !run, nil
+}
 
-		stepState.Status = StepStatusCompleted
-		stepState.Output = output
-		stepState.Error = "" // Clear error on success
+// failStep marks a step and its execution as failed without a retry. The caller must hold r.mu.
+func (r *executionRun) failStep(stepState *StepState, err error) {
+	stepState.Status = StepStatusFailed
+	stepState.Error = err.Error()
+	r.execution.Status = ExecutionStatusFailed
+	_ = r.save() // Attempt to save state
+}
 
-		// Save state after each step (S9.2.3)
-		if e.store != nil {
-			if err := e.store.SaveExecution(execution); err != nil {
-				return execution, fmt.Errorf("failed to save execution state after step %s: %w", stepID, err)
+// stepInputs builds the dispatch parameters for a step: the workflow inputs, then the
+// outputs of its parent steps, then its own params with templates resolved against data.
+// With strict wiring the step receives its own params only. The caller must hold r.mu.
+func (r *executionRun) stepInputs(step Step, data map[string]interface{}) (map[string]interface{}, error) {
+	if r.workflow.Wiring == WiringStrict {
+		params, err := ResolveParams(step.Params, data)
+		if err != nil || params != nil {
+			return params, err
+		}
+		return make(map[string]interface{}), nil
+	}
+
+	stepInputs := make(map[string]interface{})
+	// Start with the initial inputs to the workflow
+	for k, v := range r.inputs {
+		stepInputs[k] = v
+	}
+	// Add outputs from parent steps
+	for _, parentID := range dependenciesOf(r.workflow, step.ID) {
+		if parentState, ok := r.execution.StepStates[parentID]; ok && parentState.Status == StepStatusCompleted {
+			for k, v := range parentState.Output {
+				stepInputs[k] = v
 			}
 		}
 	}
+	// Add parameters defined in the step itself, which take precedence
+	params, err := ResolveParams(step.Params, data)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range params {
+		stepInputs[k] = v
+	}
+	return stepInputs, nil
+}
 
-	execution.Status = ExecutionStatusCompleted // Use the new enum
-	if e.store != nil {
-		_ = e.store.SaveExecution(execution) // Final save
+// save persists the execution. If the store reports that another process has cancelled it,
+// the run is cancelled instead and stops at its next cancellation check. The caller must
+// hold r.mu.
+func (r *executionRun) save() error {
+	if r.engine.store == nil {
+		return nil
+	}
+	err := r.engine.store.SaveExecution(r.execution)
+	if errors.Is(err, ErrExecutionCancelled) && r.execution.Status != ExecutionStatusCancelled {
+		r.execution.Status = ExecutionStatusCancelled
+		r.cancel()
+		return nil
 	}
+	return err
+}
+
+// Validate checks the workflow definition the way Execute does before running it: the
+// graph, timeouts, retry policies, error handling, signals, timers, sub-workflows, hooks
+// and wiring. Graph problems are reported as *EdgeError and *CycleError.
+func (w *Workflow) Validate() error {
+	_, err := w.TopologicalOrder()
+	return errors.Join(err, validateDefinition(w))
+}
+
+// validateDefinition runs every check of Validate except for the graph.
+func validateDefinition(workflow *Workflow) error {
+	return errors.Join(validateTimeouts(workflow), validateRetryPolicies(workflow), validateErrorHandling(workflow), validateSignals(workflow), validateTimers(workflow), validateSubWorkflows(workflow), validateHooks(workflow), validateMatrix(workflow), validateWiring(workflow))
+}
+
+// EdgeError reports an edge, or depends_on entry, naming a step that does not exist.
+type EdgeError struct {
+	Edge Edge
+	Step string // The unknown step
+}
+
+func (e *EdgeError) Error() string {
+	return fmt.Sprintf("edge %s -> %s references unknown step %q", e.Edge.From, e.Edge.To, e.Step)
+}
+
+// CycleError reports a cycle in the workflow graph.
+type CycleError struct {
+	Path []string // Step IDs along the cycle, starting and ending with the same step
+}
 
-	return execution, nil
+func (e *CycleError) Error() string {
+	return "workflow has a cycle: " + strings.Join(e.Path, " -> ")
 }
 
-// a simple implementation of Kahn's algorithm for topological sorting.
-func topologicalSort(steps map[string]Step, edges []Edge) ([]string, error) {
-	// 1. Calculate in-degrees
-	inDegree := make(map[string]int)
-	for id := range steps {
-		inDegree[id] = 0
+// TopologicalOrder returns the IDs of the workflow's steps in an order where every step
+// comes after the steps it depends on. Ties are broken by declaration order, so the result
+// is stable across runs.
+func (w *Workflow) TopologicalOrder() ([]string, error) {
+	return topologicalSort(w.Steps, w.AllEdges())
+}
+
+// topologicalSort orders steps with Kahn's algorithm, always taking the ready step declared
+// first. Edges naming unknown steps and cycles, with the path of one of them, are reported
+// together.
+func topologicalSort(steps []Step, edges []Edge) ([]string, error) {
+	// 1. Index steps by declaration order
+	index := make(map[string]int, len(steps))
+	for i, step := range steps {
+		index[step.ID] = i
 	}
+
+	// 2. Calculate in-degrees and successors, ignoring edges that name unknown steps
+	var errs []error
+	inDegree := make([]int, len(steps))
+	successors := make([][]int, len(steps))
 	for _, edge := range edges {
-		inDegree[edge.To]++
+		from, fromOK := index[edge.From]
+		to, toOK := index[edge.To]
+		if !fromOK || !toOK {
+			unknown := edge.From
+			if fromOK {
+				unknown = edge.To
+			}
+			errs = append(errs, &EdgeError{Edge: edge, Step: unknown})
+			continue
+		}
+		inDegree[to]++
+		successors[from] = append(successors[from], to)
 	}
 
-	// 2. Initialize queue with steps with in-degree 0
-	queue := []string{}
-	for id, degree := range inDegree {
+	// 3. Initialize the ready set, kept sorted by declaration order, with steps with in-degree 0
+	var ready []int
+	for i, degree := range inDegree {
 		if degree == 0 {
-			queue = append(queue, id)
+			ready = append(ready, i)
 		}
 	}
 
-	// 3. Process queue
-	result := []string{}
-	for len(queue) > 0 {
-		id := queue[0]
-		queue = queue[1:]
-		result = append(result, id)
-
-		// 4. Decrement in-degrees of neighbors
-		for _, edge := range edges {
-			if edge.From == id {
-				inDegree[edge.To]--
-				if inDegree[edge.To] == 0 {
-					queue = append(queue, edge.To)
-				}
+	// 4. Process the ready set, decrementing the in-degrees of successors
+	result := make([]string, 0, len(steps))
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		result = append(result, steps[i].ID)
+		for _, next := range successors[i] {
+			inDegree[next]--
+			if inDegree[next] == 0 {
+				pos := sort.SearchInts(ready, next)
+				ready = append(ready[:pos], append([]int{next}, ready[pos:]...)...)
 			}
 		}
 	}
 
 	// 5. Check for cycles
 	if len(result) != len(steps) {
-		return nil, fmt.Errorf("workflow has a cycle")
+		errs = append(errs, &CycleError{Path: findCycle(steps, successors, inDegree)})
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
-
 	return result, nil
 }
+
+// findCycle returns the step IDs along one cycle among the steps that topologicalSort could
+// not order, those left with a positive in-degree, starting and ending with the same step.
+func findCycle(steps []Step, successors [][]int, inDegree []int) []string {
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make([]int, len(steps))
+	var path []int
+	var visit func(i int) []string
+	visit = func(i int) []string {
+		state[i] = onPath
+		path = append(path, i)
+		for _, next := range successors[i] {
+			switch {
+			case inDegree[next] == 0:
+				continue
+			case state[next] == onPath:
+				var cycle []string
+				for j := len(path) - 1; j >= 0; j-- {
+					if path[j] == next {
+						for _, k := range path[j:] {
+							cycle = append(cycle, steps[k].ID)
+						}
+						break
+					}
+				}
+				return append(cycle, steps[next].ID)
+			case state[next] == unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		state[i] = done
+		path = path[:len(path)-1]
+		return nil
+	}
+	for i := range steps {
+		if inDegree[i] > 0 && state[i] == unvisited {
+			if cycle := visit(i); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -24,12 +26,16 @@ func (m *MockDispatcher) Dispatch(ctx context.Context, tool string, params map[s
 // MockStore is a mock implementation of the Store interface for testing.
 type MockStore struct {
 	Executions map[string]*Execution
+	Signals    map[string]map[string]interface{}
 }
 
 func (m *MockStore) SaveExecution(execution *Execution) error {
 	if m.Executions == nil {
 		m.Executions = make(map[string]*Execution)
 	}
+	if stored := m.Executions[execution.ID]; stored != nil && stored.Status == ExecutionStatusCancelled && execution.Status != ExecutionStatusCancelled {
+		return fmt.Errorf("execution %s: %w", execution.ID, ErrExecutionCancelled)
+	}
 	m.Executions[execution.ID] = execution
 	return nil
 }
@@ -51,13 +57,27 @@ func (m *MockStore) ListPendingExecutions() ([]*Execution, error) {
 		return pending, nil
 	}
 	for _, exec := range m.Executions {
-		if exec.Status == ExecutionStatusRunning || exec.Status == ExecutionStatusRetrying {
+		switch exec.Status {
+		case ExecutionStatusRunning, ExecutionStatusRetrying, ExecutionStatusWaiting, ExecutionStatusCompensating, ExecutionStatusFinalizing:
 			pending = append(pending, exec)
 		}
 	}
 	return pending, nil
 }
 
+func (m *MockStore) SaveSignal(executionID, stepID string, payload map[string]interface{}) error {
+	if m.Signals == nil {
+		m.Signals = make(map[string]map[string]interface{})
+	}
+	m.Signals[executionID+"/"+stepID] = payload
+	return nil
+}
+
+func (m *MockStore) LoadSignal(executionID, stepID string) (map[string]interface{}, bool, error) {
+	payload, ok := m.Signals[executionID+"/"+stepID]
+	return payload, ok, nil
+}
+
 func TestEngine_Execute_LinearWorkflow(t *testing.T) {
 	// 1. Setup
 	dispatcher := &MockDispatcher{
@@ -164,10 +184,10 @@ func TestEngine_Execute_FailingWorkflow(t *testing.T) {
 
 func TestTopologicalSort(t *testing.T) {
 	t.Run("simple linear workflow", func(t *testing.T) {
-		steps := map[string]Step{
-			"node-1": {ID: "node-1"},
-			"node-2": {ID: "node-2"},
-			"node-3": {ID: "node-3"},
+		steps := []Step{
+			{ID: "node-1"},
+			{ID: "node-2"},
+			{ID: "node-3"},
 		}
 		edges := []Edge{
 			{From: "node-1", To: "node-2"},
@@ -184,11 +204,11 @@ func TestTopologicalSort(t *testing.T) {
 	})
 
 	t.Run("workflow with a branch", func(t *testing.T) {
-		steps := map[string]Step{
-			"node-1": {ID: "node-1"},
-			"node-2": {ID: "node-2"},
-			"node-3": {ID: "node-3"},
-			"node-4": {ID: "node-4"},
+		steps := []Step{
+			{ID: "node-1"},
+			{ID: "node-2"},
+			{ID: "node-3"},
+			{ID: "node-4"},
 		}
 		edges := []Edge{
 			{From: "node-1", To: "node-2"},
@@ -201,9 +221,9 @@ func TestTopologicalSort(t *testing.T) {
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
-		// The exact order of node-2 and node-3 can vary, so we check for both possibilities.
-		if !(sorted[1] == "node-2" && sorted[2] == "node-3") && !(sorted[1] == "node-3" && sorted[2] == "node-2") { //nolint:staticcheck // Ignoring De Morgan's law suggestion as current form is clear and linter is overly aggressive
-			t.Errorf("expected sorted order of node-2 and node-3 to be flexible, got %v", sorted)
+		// node-2 and node-3 are both ready after node-1; declaration order breaks the tie.
+		if sorted[1] != "node-2" || sorted[2] != "node-3" {
+			t.Errorf("expected node-2 before node-3, got %v", sorted)
 		}
 		if sorted[0] != "node-1" {
 			t.Errorf("expected first node to be %q, got %q", "node-1", sorted[0])
@@ -214,10 +234,10 @@ func TestTopologicalSort(t *testing.T) {
 	})
 
 	t.Run("workflow with a cycle", func(t *testing.T) {
-		steps := map[string]Step{
-			"node-1": {ID: "node-1"},
-			"node-2": {ID: "node-2"},
-			"node-3": {ID: "node-3"},
+		steps := []Step{
+			{ID: "node-1"},
+			{ID: "node-2"},
+			{ID: "node-3"},
 		}
 		edges := []Edge{
 			{From: "node-1", To: "node-2"},
@@ -229,8 +249,54 @@ func TestTopologicalSort(t *testing.T) {
 		if err == nil {
 			t.Fatalf("expected an error, got none")
 		}
-		if !strings.Contains(err.Error(), "workflow has a cycle") {
-			t.Errorf("expected error to contain %q, got %q", "workflow has a cycle", err.Error())
+		if !strings.Contains(err.Error(), "workflow has a cycle: node-1 -> node-2 -> node-3 -> node-1") {
+			t.Errorf("expected error to contain %q, got %q", "workflow has a cycle: node-1 -> node-2 -> node-3 -> node-1", err.Error())
+		}
+	})
+
+	t.Run("ties broken by declaration order", func(t *testing.T) {
+		steps := []Step{{ID: "c"}, {ID: "a"}, {ID: "d"}, {ID: "b"}}
+		edges := []Edge{{From: "d", To: "a"}}
+
+		for i := 0; i < 10; i++ {
+			sorted, err := topologicalSort(steps, edges)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(sorted, ",") != "c,d,a,b" {
+				t.Fatalf("expected sorted %v, got %v", []string{"c", "d", "a", "b"}, sorted)
+			}
+		}
+	})
+
+	t.Run("cycle path excludes steps outside the cycle", func(t *testing.T) {
+		steps := []Step{{ID: "start"}, {ID: "after"}, {ID: "a"}, {ID: "b"}, {ID: "c"}}
+		edges := []Edge{
+			{From: "start", To: "a"},
+			{From: "c", To: "after"},
+			{From: "a", To: "b"},
+			{From: "b", To: "c"},
+			{From: "c", To: "b"},
+		}
+
+		_, err := topologicalSort(steps, edges)
+		if err == nil || err.Error() != "workflow has a cycle: b -> c -> b" {
+			t.Errorf("expected error %q, got %v", "workflow has a cycle: b -> c -> b", err)
+		}
+	})
+
+	t.Run("edges to unknown steps", func(t *testing.T) {
+		steps := []Step{{ID: "a"}, {ID: "b"}}
+		edges := []Edge{{From: "a", To: "b"}, {From: "a", To: "missing"}, {From: "ghost", To: "b"}}
+
+		_, err := topologicalSort(steps, edges)
+		if err == nil {
+			t.Fatalf("expected an error, got none")
+		}
+		for _, want := range []string{`edge a -> missing references unknown step "missing"`, `edge ghost -> b references unknown step "ghost"`} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected error %q to contain %q", err, want)
+			}
 		}
 	})
 }
@@ -507,3 +573,199 @@ func TestEngine_RetryLogic(t *testing.T) {
 		t.Errorf("expected error to contain %q, got %q", "simulated transient error on attempt 1", execResultFailed.StepStates["flaky_step_failed"].Error)
 	}
 }
+
+func TestEngine_Execute_RunsIndependentStepsConcurrently(t *testing.T) {
+	// Both roots block until the other one has started, so the workflow can only
+	// finish if the engine dispatches them at the same time.
+	var started sync.WaitGroup
+	started.Add(2)
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			switch tool {
+			case "sire:local/left", "sire:local/right":
+				started.Done()
+				done := make(chan struct{})
+				go func() {
+					started.Wait()
+					close(done)
+				}()
+				select {
+				case <-done:
+				case <-time.After(2 * time.Second):
+					return nil, fmt.Errorf("%s was not dispatched concurrently", tool)
+				}
+				return map[string]interface{}{tool: true}, nil
+			case "sire:local/join":
+				return map[string]interface{}{"joined": params["sire:local/left"] == true && params["sire:local/right"] == true}, nil
+			default:
+				return nil, fmt.Errorf("unknown tool: %s", tool)
+			}
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{})
+
+	workflow := &Workflow{
+		ID: "wf-parallel",
+		Steps: []Step{
+			{ID: "left", Tool: "sire:local/left"},
+			{ID: "right", Tool: "sire:local/right"},
+			{ID: "join", Tool: "sire:local/join"},
+		},
+		Edges: []Edge{
+			{From: "left", To: "join"},
+			{From: "right", To: "join"},
+		},
+	}
+	execution := &Execution{
+		ID:         "exec-parallel-1",
+		WorkflowID: workflow.ID,
+		Status:     ExecutionStatusRunning,
+		StepStates: make(map[string]*StepState),
+	}
+
+	execResult, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if execResult.Status != ExecutionStatusCompleted {
+		t.Errorf("expected status %q, got %q", ExecutionStatusCompleted, execResult.Status)
+	}
+	if execResult.StepStates["join"].Output["joined"] != true {
+		t.Errorf("expected join to see both parent outputs, got %v", execResult.StepStates["join"].Output)
+	}
+}
+
+func TestEngine_Execute_MaxParallelism(t *testing.T) {
+	var inFlight, maxInFlight int32
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				current := atomic.LoadInt32(&maxInFlight)
+				if n <= current || atomic.CompareAndSwapInt32(&maxInFlight, current, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return map[string]interface{}{}, nil
+		},
+	}
+
+	workflow := &Workflow{
+		ID: "wf-fan-out",
+		Steps: []Step{
+			{ID: "a", Tool: "sire:local/work"},
+			{ID: "b", Tool: "sire:local/work"},
+			{ID: "c", Tool: "sire:local/work"},
+			{ID: "d", Tool: "sire:local/work"},
+		},
+	}
+
+	t.Run("engine default", func(t *testing.T) {
+		atomic.StoreInt32(&maxInFlight, 0)
+		engine := NewEngine(dispatcher, &MockStore{}, WithMaxParallelism(2))
+		execution := &Execution{ID: "exec-fan-out-1", StepStates: make(map[string]*StepState)}
+		if _, err := engine.Execute(context.Background(), execution, workflow, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := atomic.LoadInt32(&maxInFlight); got > 2 {
+			t.Errorf("expected at most %d steps in flight, got %d", 2, got)
+		}
+	})
+
+	t.Run("execution override", func(t *testing.T) {
+		atomic.StoreInt32(&maxInFlight, 0)
+		engine := NewEngine(dispatcher, &MockStore{}, WithMaxParallelism(4))
+		execution := &Execution{ID: "exec-fan-out-2", StepStates: make(map[string]*StepState), MaxParallelism: 1}
+		if _, err := engine.Execute(context.Background(), execution, workflow, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := atomic.LoadInt32(&maxInFlight); got != 1 {
+			t.Errorf("expected exactly %d step in flight, got %d", 1, got)
+		}
+	})
+}
+
+func TestEngine_Execute_ConditionalSteps(t *testing.T) {
+	var dispatched []string
+	var mu sync.Mutex
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			mu.Lock()
+			dispatched = append(dispatched, tool)
+			mu.Unlock()
+			if tool == "sire:local/check" {
+				return map[string]interface{}{"healthy": false}, nil
+			}
+			return map[string]interface{}{"tool": tool}, nil
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{})
+
+	// check -> deploy -> announce
+	// check -> rollback
+	// deploy, rollback -> report (joins the two exclusive branches)
+	workflow := &Workflow{
+		ID: "wf-conditional",
+		Steps: []Step{
+			{ID: "check", Tool: "sire:local/check"},
+			{ID: "deploy", Tool: "sire:local/deploy", When: "steps.check.output.healthy"},
+			{ID: "rollback", Tool: "sire:local/rollback", When: "!check.output.healthy && inputs.env == 'prod'"},
+			{ID: "announce", Tool: "sire:local/announce"},
+			{ID: "report", Tool: "sire:local/report", SkipPolicy: SkipPolicyIgnore},
+		},
+		Edges: []Edge{
+			{From: "check", To: "deploy"},
+			{From: "check", To: "rollback"},
+			{From: "deploy", To: "announce"},
+			{From: "deploy", To: "report"},
+			{From: "rollback", To: "report"},
+		},
+	}
+	execution := &Execution{ID: "exec-conditional-1", StepStates: make(map[string]*StepState)}
+
+	execResult, err := engine.Execute(context.Background(), execution, workflow, map[string]interface{}{"env": "prod"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if execResult.Status != ExecutionStatusCompleted {
+		t.Errorf("expected status %q, got %q", ExecutionStatusCompleted, execResult.Status)
+	}
+
+	expected := map[string]StepStatus{
+		"check":    StepStatusCompleted,
+		"deploy":   StepStatusSkipped,
+		"rollback": StepStatusCompleted,
+		"announce": StepStatusSkipped, // skip propagated from deploy
+		"report":   StepStatusCompleted,
+	}
+	for stepID, status := range expected {
+		if got := execResult.StepStates[stepID].Status; got != status {
+			t.Errorf("expected step %s status %q, got %q", stepID, status, got)
+		}
+	}
+	if len(dispatched) != 3 {
+		t.Errorf("expected %d dispatched tools, got %v", 3, dispatched)
+	}
+}
+
+func TestEngine_Execute_InvalidCondition(t *testing.T) {
+	engine := NewEngine(&MockDispatcher{}, &MockStore{})
+	workflow := &Workflow{
+		ID:    "wf-bad-condition",
+		Steps: []Step{{ID: "a", Tool: "sire:local/a", When: "unknown_name > 1"}},
+	}
+	execution := &Execution{ID: "exec-bad-condition-1", StepStates: make(map[string]*StepState)}
+
+	execResult, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if execResult.Status != ExecutionStatusFailed {
+		t.Errorf("expected status %q, got %q", ExecutionStatusFailed, execResult.Status)
+	}
+	if execResult.StepStates["a"].Status != StepStatusFailed {
+		t.Errorf("expected status %q, got %q", StepStatusFailed, execResult.StepStates["a"].Status)
+	}
+}
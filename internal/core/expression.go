@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/expr-lang/expr"
+)
+
+// evalCondition evaluates an expr-lang boolean expression, such as a step's `when`,
+// against the same data that step param templates see (see buildTemplateData).
+func evalCondition(expression string, data map[string]interface{}) (bool, error) {
+	program, err := expr.Compile(expression, expr.Env(data), expr.AsBool())
+	if err != nil {
+		return false, fmt.Errorf("invalid condition %q: %w", expression, err)
+	}
+	output, err := expr.Run(program, data)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate condition %q: %w", expression, err)
+	}
+	return output.(bool), nil
+}
+
+// evalExpression evaluates an expr-lang expression against data and returns its value.
+func evalExpression(expression string, data map[string]interface{}) (interface{}, error) {
+	program, err := expr.Compile(expression, expr.Env(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", expression, err)
+	}
+	output, err := expr.Run(program, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate expression %q: %w", expression, err)
+	}
+	return output, nil
+}
+
+// evalList evaluates an expr-lang expression, such as a step's `foreach`, that must yield a list.
+func evalList(expression string, data map[string]interface{}) ([]interface{}, error) {
+	output, err := evalExpression(expression, data)
+	if err != nil {
+		return nil, err
+	}
+	if output == nil {
+		return []interface{}{}, nil
+	}
+	v := reflect.ValueOf(output)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expression %q must yield a list, got %T", expression, output)
+	}
+	list := make([]interface{}, v.Len())
+	for i := range list {
+		list[i] = v.Index(i).Interface()
+	}
+	return list, nil
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Finished foreach items are saved once foreachSaveInterval has passed or foreachSaveItems
+// items have finished since the last save, rather than after every item, which for long
+// lists would rewrite the whole execution once per item. A crash loses at most that much
+// progress, whose items run again on resume.
+const (
+	foreachSaveInterval = time.Second
+	foreachSaveItems    = 100
+)
+
+// itemProgress throttles the saves of a foreach step's item states. It is guarded by r.mu.
+type itemProgress struct {
+	saved   time.Time // When the execution was last saved
+	unsaved int       // Items finished since then
+}
+
+// itemDone records that an item has finished and saves the execution if it is due. The
+// caller must hold r.mu.
+func (p *itemProgress) itemDone(r *executionRun) error {
+	p.unsaved++
+	if p.unsaved < foreachSaveItems && time.Since(p.saved) < foreachSaveInterval {
+		return nil
+	}
+	p.saved, p.unsaved = time.Now(), 0
+	return r.save()
+}
+
+// runForeach runs a foreach step: its tool is dispatched once per item of the list that
+// step.Foreach evaluates to by step.Concurrency workers, or one per item if unlimited.
+// Progress is kept per item in stepState.Items and saved periodically, so a resumed
+// execution only re-runs unfinished items.
+// The step output is {"results": [...]}, ordered like the input list.
+func (r *executionRun) runForeach(ctx context.Context, step Step, stepState *StepState) error {
+	r.mu.Lock()
+	data := r.stepData(step)
+	items, err := evalList(step.Foreach, data)
+	if err != nil {
+		r.failStep(stepState, err)
+		r.mu.Unlock()
+		return fmt.Errorf("error evaluating foreach for step %s: %w", step.ID, err)
+	}
+	if len(stepState.Items) != len(items) {
+		// First run, or the upstream list changed: start over.
+		stepState.Items = make([]*ItemState, len(items))
+		for i := range stepState.Items {
+			stepState.Items[i] = &ItemState{Status: StepStatusPending}
+		}
+	}
+	var pending []int
+	for i, itemState := range stepState.Items {
+		if itemState.Status != StepStatusCompleted {
+			pending = append(pending, i)
+		}
+	}
+	stepState.Attempts++
+	stepState.Status = StepStatusRunning
+	r.mu.Unlock()
+
+	limit := step.Concurrency
+	if limit <= 0 || limit > len(pending) {
+		limit = len(pending)
+	}
+	progress := &itemProgress{saved: time.Now()}
+	errs := make([]error, len(pending))
+	next := make(chan int) // Positions in pending
+	var wg sync.WaitGroup
+	for range limit {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range next {
+				i := pending[n]
+				errs[n] = r.runItem(ctx, step, stepState.Items[i], withItem(data, i, items[i]), progress)
+				if errs[n] != nil {
+					errs[n] = fmt.Errorf("item %d: %w", i, errs[n])
+				}
+			}
+		}()
+	}
+	for n := range pending {
+		next <- n
+	}
+	close(next)
+	wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, err := range errs {
+		if err != nil {
+			return r.recordFailure(ctx, step, stepState, err)
+		}
+	}
+
+	results := make([]interface{}, len(stepState.Items))
+	for i, itemState := range stepState.Items {
+		results[i] = itemState.Output
+	}
+	return r.completeStep(step, stepState, map[string]interface{}{"results": results})
+}
+
+// runItem dispatches the step's tool for a single foreach item and records the item's state,
+// which progress saves once due.
+func (r *executionRun) runItem(ctx context.Context, step Step, itemState *ItemState, data map[string]interface{}, progress *itemProgress) error {
+	r.mu.Lock()
+	if r.execution.Status == ExecutionStatusCancelled {
+		r.mu.Unlock()
+		return ErrExecutionCancelled
+	}
+	itemInputs, err := r.stepInputs(step, data)
+	if err != nil {
+		itemState.Status = StepStatusFailed
+		itemState.Error = err.Error()
+		r.mu.Unlock()
+		return err
+	}
+	itemState.Attempts++
+	itemState.Status = StepStatusRunning
+	r.mu.Unlock()
+
+	output, err := r.dispatch(ctx, step, itemInputs)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		itemState.Status = StepStatusFailed
+		if r.execution.Status == ExecutionStatusCancelled {
+			itemState.Status = StepStatusCancelled
+		}
+		itemState.Error = err.Error()
+		_ = progress.itemDone(r) // Attempt to save state
+		return err
+	}
+	itemState.Status = StepStatusCompleted
+	itemState.Output = output
+	itemState.Error = ""
+	if err := progress.itemDone(r); err != nil {
+		return fmt.Errorf("failed to save execution state: %w", err)
+	}
+	return nil
+}
+
+// withItem returns a copy of data that also exposes the current foreach item as .item and its position as .index.
+func withItem(data map[string]interface{}, index int, item interface{}) map[string]interface{} {
+	itemData := make(map[string]interface{}, len(data)+2)
+	for k, v := range data {
+		itemData[k] = v
+	}
+	itemData["item"] = item
+	itemData["index"] = index
+	return itemData
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEngine_Execute_Foreach(t *testing.T) {
+	var inFlight, maxInFlight int32
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			switch tool {
+			case "sire:local/list":
+				return map[string]interface{}{"records": []interface{}{1.0, 2.0, 3.0, 4.0, 5.0}}, nil
+			case "sire:local/double":
+				n := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					current := atomic.LoadInt32(&maxInFlight)
+					if n <= current || atomic.CompareAndSwapInt32(&maxInFlight, current, n) {
+						break
+					}
+				}
+				// Finish later items first so ordering is not an accident of timing.
+				value := params["value"].(float64)
+				time.Sleep(time.Duration(10-int(value)) * time.Millisecond)
+				return map[string]interface{}{"value": value * 2, "index": params["index"]}, nil
+			default:
+				return nil, fmt.Errorf("unknown tool: %s", tool)
+			}
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{})
+
+	workflow := &Workflow{
+		ID: "wf-foreach",
+		Steps: []Step{
+			{ID: "list", Tool: "sire:local/list"},
+			{
+				ID:          "double",
+				Tool:        "sire:local/double",
+				Foreach:     "steps.list.output.records",
+				Concurrency: 2,
+				Params:      map[string]interface{}{"value": "{{ .item }}", "index": "{{ .index }}"},
+			},
+		},
+		Edges: []Edge{{From: "list", To: "double"}},
+	}
+	execution := &Execution{ID: "exec-foreach-1", StepStates: make(map[string]*StepState)}
+
+	execResult, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(&maxInFlight); got > 2 {
+		t.Errorf("expected at most %d items in flight, got %d", 2, got)
+	}
+
+	results, ok := execResult.StepStates["double"].Output["results"].([]interface{})
+	if !ok || len(results) != 5 {
+		t.Fatalf("expected 5 results, got %#v", execResult.StepStates["double"].Output)
+	}
+	for i, result := range results {
+		output := result.(map[string]interface{})
+		if output["value"] != float64(i+1)*2 || output["index"] != i {
+			t.Errorf("expected result %d to be %v at index %d, got %v", i, float64(i+1)*2, i, output)
+		}
+	}
+}
+
+func TestEngine_Execute_ForeachResumesUnfinishedItems(t *testing.T) {
+	var mu sync.Mutex
+	var calls []interface{}
+	failing := true
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, params["item"])
+			if params["item"] == "c" && failing {
+				return nil, fmt.Errorf("transient failure")
+			}
+			return map[string]interface{}{"upper": params["item"].(string) + "!"}, nil
+		},
+	}
+	store := &MockStore{}
+	engine := NewEngine(dispatcher, store)
+
+	workflow := &Workflow{
+		ID: "wf-foreach-resume",
+		Steps: []Step{{
+			ID:          "shout",
+			Tool:        "sire:local/shout",
+			Foreach:     "inputs.letters",
+			Concurrency: 1,
+			Params:      map[string]interface{}{"item": "{{ .item }}"},
+		}},
+	}
+	execution := &Execution{ID: "exec-foreach-resume-1", StepStates: make(map[string]*StepState)}
+	inputs := map[string]interface{}{"letters": []interface{}{"a", "b", "c", "d"}}
+
+	execResult, err := engine.Execute(context.Background(), execution, workflow, inputs)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	items := execResult.StepStates["shout"].Items
+	if len(items) != 4 || items[0].Status != StepStatusCompleted || items[2].Status != StepStatusFailed {
+		t.Fatalf("expected per-item progress to be recorded, got %+v", items)
+	}
+
+	// Resume: only the failed item is dispatched again.
+	mu.Lock()
+	failing = false
+	calls = nil
+	mu.Unlock()
+	resumed, err := store.LoadExecution("exec-foreach-resume-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	execResult, err = engine.Execute(context.Background(), resumed, workflow, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(calls, []interface{}{"c"}) {
+		t.Errorf("expected only item %q to be re-run, got %v", "c", calls)
+	}
+	results := execResult.StepStates["shout"].Output["results"].([]interface{})
+	if results[2].(map[string]interface{})["upper"] != "c!" || results[3].(map[string]interface{})["upper"] != "d!" {
+		t.Errorf("unexpected results %v", results)
+	}
+}
+
+// countingStore counts the saves of executions.
+type countingStore struct {
+	MockStore
+	saves atomic.Int32
+}
+
+func (s *countingStore) SaveExecution(execution *Execution) error {
+	s.saves.Add(1)
+	return s.MockStore.SaveExecution(execution)
+}
+
+func TestEngine_Execute_ForeachThrottlesSaves(t *testing.T) {
+	const count = 1000
+	letters := make([]interface{}, count)
+	for i := range letters {
+		letters[i] = fmt.Sprint(i)
+	}
+	store := &countingStore{}
+	engine := NewEngine(&MockDispatcher{}, store)
+	workflow := &Workflow{
+		ID:    "wf-foreach-large",
+		Steps: []Step{{ID: "each", Tool: "sire:local/each", Foreach: "inputs.letters", Concurrency: 4}},
+	}
+	execution := &Execution{ID: "exec-foreach-large-1", StepStates: make(map[string]*StepState)}
+
+	execResult, err := engine.Execute(context.Background(), execution, workflow, map[string]interface{}{"letters": letters})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := len(execResult.StepStates["each"].Output["results"].([]interface{})); got != count {
+		t.Fatalf("expected %d results, got %d", count, got)
+	}
+	// One save per foreachSaveItems items, and a few around the step itself.
+	if saves := store.saves.Load(); saves > count/foreachSaveItems+5 {
+		t.Errorf("expected item saves to be batched, got %d saves for %d items", saves, count)
+	}
+}
@@ -0,0 +1,192 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// hooks returns the workflow's hook steps: on_success, on_failure, then finally.
+func (w *Workflow) hooks() []Step {
+	hooks := make([]Step, 0, len(w.OnSuccess)+len(w.OnFailure)+len(w.Finally))
+	hooks = append(hooks, w.OnSuccess...)
+	hooks = append(hooks, w.OnFailure...)
+	return append(hooks, w.Finally...)
+}
+
+// finish brings an execution whose steps have stopped to its final status, running the
+// hooks for its outcome first. err is the error the steps (and any rollback) ended with.
+// Executions that are still in progress, e.g. waiting for a retry, are left as they are.
+func (r *executionRun) finish(ctx context.Context, err error) error {
+	r.mu.Lock()
+	if r.checkCancelled() {
+		r.mu.Unlock()
+		return err
+	}
+	outcome := ExecutionStatusCompleted
+	switch {
+	case r.execution.Status == ExecutionStatusFinalizing:
+		// A resumed execution finishes with the outcome recorded when its hooks started.
+		if r.execution.Outcome != ExecutionStatusCompleted {
+			outcome = ExecutionStatusFailed
+		}
+	case err == nil:
+	case r.execution.Status == ExecutionStatusFailed:
+		outcome = ExecutionStatusFailed
+	default:
+		r.mu.Unlock()
+		return err
+	}
+	hooks := append(append([]Step{}, r.workflow.OnSuccess...), r.workflow.Finally...)
+	if outcome == ExecutionStatusFailed {
+		hooks = append(append([]Step{}, r.workflow.OnFailure...), r.workflow.Finally...)
+	}
+	// Hooks run under their own status, so that after a crash the agent resumes the ones
+	// that have not finished instead of running them all again.
+	if len(hooks) > 0 {
+		r.execution.Status = ExecutionStatusFinalizing
+		r.execution.Outcome = outcome
+		_ = r.save() // Attempt to save state
+	}
+	r.mu.Unlock()
+
+	var hookErrs []error
+	for _, hook := range hooks {
+		if hookErr := r.runHook(ctx, hook, outcome); hookErr != nil {
+			hookErrs = append(hookErrs, hookErr)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.checkCancelled() {
+		return r.finishCancelled()
+	}
+	r.execution.Status = outcome
+	r.execution.Outcome = ""
+	if saveErr := r.save(); saveErr != nil && err == nil {
+		err = fmt.Errorf("failed to save execution state: %w", saveErr)
+	}
+	return errors.Join(append([]error{err}, hookErrs...)...)
+}
+
+// runHook runs a single hook step, unless it already finished in an earlier run. Hooks are
+// dispatched once; a failure is recorded in the hook's state without changing the outcome.
+func (r *executionRun) runHook(ctx context.Context, hook Step, outcome ExecutionStatus) error {
+	r.mu.Lock()
+	if r.checkCancelled() {
+		r.mu.Unlock()
+		return nil
+	}
+	stepState, ok := r.execution.StepStates[hook.ID]
+	if !ok {
+		stepState = &StepState{Status: StepStatusPending}
+		r.execution.StepStates[hook.ID] = stepState
+	}
+	switch stepState.Status {
+	case StepStatusCompleted, StepStatusSkipped, StepStatusFailed:
+		r.mu.Unlock()
+		return nil
+	}
+
+	data := r.hookData(outcome)
+	if hook.When != "" {
+		run, err := evalCondition(hook.When, data)
+		if err != nil {
+			return r.failHook(hook, stepState, err)
+		}
+		if !run {
+			stepState.Status = StepStatusSkipped
+			_ = r.save() // Attempt to save state
+			r.mu.Unlock()
+			return nil
+		}
+	}
+
+	// Hooks receive the outcome as inputs; their own params take precedence.
+	params, err := r.stepInputs(hook, data)
+	if err != nil {
+		return r.failHook(hook, stepState, err)
+	}
+	execution := data["execution"].(map[string]interface{})
+	for _, key := range []string{"status", "failed_steps", "errors"} {
+		name := "execution_" + key
+		if _, ok := params[name]; !ok {
+			params[name] = execution[key]
+		}
+	}
+	stepState.Attempts++
+	stepState.Status = StepStatusRunning
+	_ = r.save() // Attempt to save state
+	r.mu.Unlock()
+
+	output, err := r.dispatch(ctx, hook, params)
+
+	r.mu.Lock()
+	if err != nil {
+		return r.failHook(hook, stepState, err)
+	}
+	stepState.Status = StepStatusCompleted
+	stepState.Output = output
+	stepState.Error = ""
+	defer r.mu.Unlock()
+	if err := r.save(); err != nil {
+		return fmt.Errorf("failed to save execution state after hook %s: %w", hook.ID, err)
+	}
+	return nil
+}
+
+// failHook records a hook failure and releases r.mu, which the caller must hold.
+func (r *executionRun) failHook(hook Step, stepState *StepState, err error) error {
+	defer r.mu.Unlock()
+	stepState.Status = StepStatusFailed
+	stepState.Error = err.Error()
+	_ = r.save() // Attempt to save state
+	return fmt.Errorf("error executing hook %s: %w", hook.ID, err)
+}
+
+// hookData returns the template data for hooks: the usual data, with .execution extended
+// by the outcome as status, the IDs of the failed steps as failed_steps (in declaration
+// order) and their errors by step ID as errors. The caller must hold r.mu.
+func (r *executionRun) hookData(outcome ExecutionStatus) map[string]interface{} {
+	failed := []interface{}{}
+	errs := map[string]interface{}{}
+	for _, step := range r.workflow.Steps {
+		if stepState, ok := r.execution.StepStates[step.ID]; ok && stepState.Status == StepStatusFailed {
+			failed = append(failed, step.ID)
+			errs[step.ID] = stepState.Error
+		}
+	}
+	data := buildTemplateData(r.execution, r.workflow, r.inputs)
+	data["execution"] = map[string]interface{}{
+		"id":           r.execution.ID,
+		"status":       string(outcome),
+		"failed_steps": failed,
+		"errors":       errs,
+	}
+	return data
+}
+
+// validateHooks checks that hook IDs are unique across the workflow and that hooks only
+// use the fields they support.
+func validateHooks(workflow *Workflow) error {
+	var errs []error
+	seen := make(map[string]bool, len(workflow.Steps))
+	for _, step := range workflow.Steps {
+		seen[step.ID] = true
+	}
+	for _, hook := range workflow.hooks() {
+		if hook.ID == "" {
+			errs = append(errs, fmt.Errorf("hook with tool %q has no id", hook.Tool))
+			continue
+		}
+		if seen[hook.ID] {
+			errs = append(errs, fmt.Errorf("hook %s: id is already used by another step or hook", hook.ID))
+		}
+		seen[hook.ID] = true
+		if hook.Foreach != "" || hook.Workflow != "" || hook.Retry != nil || hook.Compensate != nil || hook.OnError != "" || hook.Fallback != nil || hook.Signal != nil || hook.isTimer() || len(hook.Matrix) > 0 {
+			errs = append(errs, fmt.Errorf("hook %s: hooks support only tool, params, when and timeout", hook.ID))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,232 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func hookWorkflow(failBuild bool) *Workflow {
+	buildTool := "sire:local/test.ok"
+	if failBuild {
+		buildTool = "sire:local/test.fail"
+	}
+	return &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "build", Tool: buildTool},
+		},
+		OnSuccess: []Step{
+			{ID: "announce", Tool: "sire:local/test.announce"},
+		},
+		OnFailure: []Step{
+			{ID: "alert", Tool: "sire:local/test.alert", Params: map[string]interface{}{"message": "{{ .execution.errors.build }}"}},
+			{ID: "page", Tool: "sire:local/test.page", When: "len(execution.failed_steps) > 1"},
+		},
+		Finally: []Step{
+			{ID: "cleanup", Tool: "sire:local/test.cleanup"},
+		},
+	}
+}
+
+func TestEngine_Execute_SuccessHooks(t *testing.T) {
+	var calls []string
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			calls = append(calls, fmt.Sprintf("%s:%v", tool, params["execution_status"]))
+			return map[string]interface{}{}, nil
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{})
+
+	workflow := hookWorkflow(false)
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+	execution, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"sire:local/test.ok:<nil>", "sire:local/test.announce:completed", "sire:local/test.cleanup:completed"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected calls %v, got %v", want, calls)
+	}
+	if execution.Status != ExecutionStatusCompleted {
+		t.Errorf("expected status %q, got %q", ExecutionStatusCompleted, execution.Status)
+	}
+	if _, ok := execution.Outputs["announce"]; ok {
+		t.Errorf("expected hooks not to contribute to the workflow outputs")
+	}
+}
+
+func TestEngine_Execute_FailureHooks(t *testing.T) {
+	var calls []string
+	var alertParams map[string]interface{}
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			calls = append(calls, tool)
+			switch tool {
+			case "sire:local/test.fail":
+				return nil, errors.New("compiler crashed")
+			case "sire:local/test.alert":
+				alertParams = params
+				return nil, errors.New("slack unavailable")
+			}
+			return map[string]interface{}{}, nil
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{})
+
+	workflow := hookWorkflow(true)
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+	execution, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil || !strings.Contains(err.Error(), "compiler crashed") || !strings.Contains(err.Error(), "error executing hook alert") {
+		t.Fatalf("expected the step and hook errors, got %v", err)
+	}
+
+	// A failing hook does not stop the hooks after it.
+	want := []string{"sire:local/test.fail", "sire:local/test.alert", "sire:local/test.cleanup"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected calls %v, got %v", want, calls)
+	}
+	if execution.Status != ExecutionStatusFailed {
+		t.Errorf("expected status %q, got %q", ExecutionStatusFailed, execution.Status)
+	}
+	if alertParams["message"] != "compiler crashed" || alertParams["execution_status"] != "failed" {
+		t.Errorf("unexpected alert params: %v", alertParams)
+	}
+	if got := alertParams["execution_failed_steps"]; !reflect.DeepEqual(got, []interface{}{"build"}) {
+		t.Errorf("expected failed steps [build], got %v", got)
+	}
+	if got := execution.StepStates["alert"]; got.Status != StepStatusFailed || got.Error != "slack unavailable" {
+		t.Errorf("expected the hook failure to be recorded, got %+v", got)
+	}
+	if got := execution.StepStates["page"].Status; got != StepStatusSkipped {
+		t.Errorf("expected the hook to be skipped by its condition, got %s", got)
+	}
+	if _, ok := execution.StepStates["announce"]; ok {
+		t.Errorf("expected on_success hooks not to run")
+	}
+}
+
+func TestEngine_Execute_ResumesFinalizing(t *testing.T) {
+	var calls []string
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			calls = append(calls, tool)
+			return map[string]interface{}{}, nil
+		},
+	}
+	store := &MockStore{}
+	engine := NewEngine(dispatcher, store)
+
+	// A crash happened after the alert hook ran.
+	workflow := hookWorkflow(true)
+	execution := &Execution{
+		ID:         "test-execution",
+		WorkflowID: workflow.ID,
+		Status:     ExecutionStatusFinalizing,
+		StepStates: map[string]*StepState{
+			"build": {Status: StepStatusFailed, Error: "compiler crashed"},
+			"alert": {Status: StepStatusCompleted},
+		},
+	}
+	_ = store.SaveExecution(execution)
+	if pending, _ := store.ListPendingExecutions(); len(pending) != 1 {
+		t.Fatalf("expected the finalizing execution to be pending, got %d", len(pending))
+	}
+
+	_, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil || !strings.Contains(err.Error(), "step build: compiler crashed") {
+		t.Fatalf("expected the original failure, got %v", err)
+	}
+	if !reflect.DeepEqual(calls, []string{"sire:local/test.cleanup"}) {
+		t.Errorf("expected only the remaining hooks to run, got %v", calls)
+	}
+	if execution.Status != ExecutionStatusFailed {
+		t.Errorf("expected status %q, got %q", ExecutionStatusFailed, execution.Status)
+	}
+}
+
+func TestEngine_Execute_ResumesFinalizingAfterSuccess(t *testing.T) {
+	var calls []string
+	store := &MockStore{}
+	var statuses []ExecutionStatus
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			calls = append(calls, tool)
+			if stored, err := store.LoadExecution("test-execution"); err == nil && tool != "sire:local/test.ok" {
+				statuses = append(statuses, stored.Status)
+			}
+			return map[string]interface{}{}, nil
+		},
+	}
+	engine := NewEngine(dispatcher, store)
+	workflow := hookWorkflow(false)
+
+	// Success hooks run under the finalizing status, like failure hooks.
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+	if _, err := engine.Execute(context.Background(), execution, workflow, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []ExecutionStatus{ExecutionStatusFinalizing, ExecutionStatusFinalizing}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("expected statuses %v while dispatching the hooks, got %v", want, statuses)
+	}
+	if execution.Status != ExecutionStatusCompleted || execution.Outcome != "" {
+		t.Errorf("expected status %q without an outcome left, got %q (outcome %q)", ExecutionStatusCompleted, execution.Status, execution.Outcome)
+	}
+
+	// A crash happened while the finally hook was running, after on_success had completed.
+	calls = nil
+	execution = &Execution{
+		ID:         "test-execution",
+		WorkflowID: workflow.ID,
+		Status:     ExecutionStatusFinalizing,
+		Outcome:    ExecutionStatusCompleted,
+		Outputs:    map[string]interface{}{"artifact": "build.tar"},
+		StepStates: map[string]*StepState{
+			"build":    {Status: StepStatusCompleted},
+			"announce": {Status: StepStatusCompleted},
+			"cleanup":  {Status: StepStatusRunning, Attempts: 1},
+		},
+	}
+	_ = store.SaveExecution(execution)
+	if pending, _ := store.ListPendingExecutions(); len(pending) != 1 {
+		t.Fatalf("expected the finalizing execution to be pending, got %d", len(pending))
+	}
+
+	if _, err := engine.Execute(context.Background(), execution, workflow, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(calls, []string{"sire:local/test.cleanup"}) {
+		t.Errorf("expected only the unfinished hook to run again, got %v", calls)
+	}
+	if execution.Status != ExecutionStatusCompleted {
+		t.Errorf("expected status %q, got %q", ExecutionStatusCompleted, execution.Status)
+	}
+	if execution.Outputs["artifact"] != "build.tar" {
+		t.Errorf("expected the outputs to be kept, got %v", execution.Outputs)
+	}
+}
+
+func TestEngine_Execute_InvalidHooks(t *testing.T) {
+	engine := NewEngine(&MockDispatcher{}, &MockStore{})
+	workflow := &Workflow{
+		ID:        "test-workflow",
+		Steps:     []Step{{ID: "build", Tool: "sire:local/test.build"}},
+		OnFailure: []Step{{ID: "build", Tool: "sire:local/test.alert"}},
+		Finally:   []Step{{ID: "cleanup", Tool: "sire:local/test.cleanup", Foreach: "inputs.dirs"}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	_, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	for _, want := range []string{"hook build: id is already used", "hook cleanup: hooks support only"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %q", want, err.Error())
+		}
+	}
+}
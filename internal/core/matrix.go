@@ -0,0 +1,316 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Matrix is the axes of a matrix step, in declaration order. In YAML it is a mapping from
+// axis name to the list of values, e.g. `{region: [eu, us], env: [staging, prod]}`.
+type Matrix []MatrixAxis
+
+// MatrixAxis is one dimension of a matrix step.
+type MatrixAxis struct {
+	Name   string        `json:"name"`
+	Values []interface{} `json:"values"`
+}
+
+// UnmarshalYAML decodes the axes of a matrix in the order they are written.
+func (m *Matrix) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: matrix must map axis names to lists of values", value.Line)
+	}
+	axes := make(Matrix, 0, len(value.Content)/2)
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		axis := MatrixAxis{Name: value.Content[i].Value}
+		if err := value.Content[i+1].Decode(&axis.Values); err != nil {
+			return err
+		}
+		axes = append(axes, axis)
+	}
+	*m = axes
+	return nil
+}
+
+// MatrixError reports a matrix step that cannot be expanded.
+type MatrixError struct {
+	StepID string
+	Err    error
+}
+
+func (e *MatrixError) Error() string {
+	return fmt.Sprintf("step %s: %v", e.StepID, e.Err)
+}
+
+func (e *MatrixError) Unwrap() error {
+	return e.Err
+}
+
+// matrixAxisName matches the axis names a matrix step can declare, which templates read as
+// .matrix.<name>.
+var matrixAxisName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// matrixIDChars are the characters that matrix values cannot contain, since they would
+// make instance IDs ambiguous.
+const matrixIDChars = " ,=[]"
+
+// ExpandMatrix replaces every step with a matrix by one instance per combination of its
+// axis values, in order, the first axis varying slowest. An instance is a copy of the step
+// without the matrix, with the combination in MatrixValues, the step's ID in MatrixOf, and
+// an ID derived from both, e.g. deploy[region=eu,env=prod]. Edges and depends_on entries
+// naming a matrix step are expanded to all of its instances. Workflows without matrix
+// steps are left unchanged. Every problem is reported as a *MatrixError.
+func (w *Workflow) ExpandMatrix() error {
+	instances := make(map[string][]string)
+	var steps []Step
+	var errs []error
+	for _, step := range w.Steps {
+		if len(step.Matrix) == 0 {
+			steps = append(steps, step)
+			continue
+		}
+		expanded, err := expandMatrixStep(step)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, instance := range expanded {
+			instances[step.ID] = append(instances[step.ID], instance.ID)
+		}
+		steps = append(steps, expanded...)
+	}
+	if len(instances) == 0 {
+		return errors.Join(errs...)
+	}
+	ids := make(map[string]bool, len(w.Steps))
+	for _, step := range w.Steps {
+		ids[step.ID] = true
+	}
+	for _, step := range steps {
+		if step.MatrixOf != "" && ids[step.ID] {
+			errs = append(errs, &MatrixError{StepID: step.MatrixOf, Err: fmt.Errorf("matrix instance %s has the ID of another step", step.ID)})
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	expand := func(ids []string) []string {
+		var expanded []string
+		for _, id := range ids {
+			if ids, ok := instances[id]; ok {
+				expanded = append(expanded, ids...)
+			} else {
+				expanded = append(expanded, id)
+			}
+		}
+		return expanded
+	}
+	for i := range steps {
+		steps[i].DependsOn = expand(steps[i].DependsOn)
+	}
+	var edges EdgeList
+	for _, edge := range w.Edges {
+		for _, from := range expand([]string{edge.From}) {
+			for _, to := range expand([]string{edge.To}) {
+				edges = append(edges, Edge{From: from, To: to})
+			}
+		}
+	}
+	w.Steps, w.Edges = steps, edges
+	return nil
+}
+
+// expandMatrixStep returns the instances of a matrix step.
+func expandMatrixStep(step Step) ([]Step, error) {
+	seen := make(map[string]bool, len(step.Matrix))
+	for _, axis := range step.Matrix {
+		switch {
+		case !matrixAxisName.MatchString(axis.Name):
+			return nil, matrixError(step, "invalid matrix axis name %q", axis.Name)
+		case seen[axis.Name]:
+			return nil, matrixError(step, "matrix axis %s is declared twice", axis.Name)
+		case len(axis.Values) == 0:
+			return nil, matrixError(step, "matrix axis %s has no values", axis.Name)
+		}
+		seen[axis.Name] = true
+		values := make(map[string]bool, len(axis.Values))
+		for _, value := range axis.Values {
+			switch value.(type) {
+			case string, int, int64, float64, bool:
+			default:
+				return nil, matrixError(step, "matrix axis %s: values must be strings, numbers or booleans, got %T", axis.Name, value)
+			}
+			text := fmt.Sprint(value)
+			switch {
+			case strings.ContainsAny(text, matrixIDChars):
+				return nil, matrixError(step, "matrix axis %s: value %q cannot contain spaces or any of %q, which delimit instance IDs", axis.Name, text, matrixIDChars[1:])
+			case values[text]:
+				return nil, matrixError(step, "matrix axis %s has the value %s twice", axis.Name, text)
+			}
+			values[text] = true
+		}
+	}
+
+	combinations := []map[string]interface{}{{}}
+	for _, axis := range step.Matrix {
+		next := make([]map[string]interface{}, 0, len(combinations)*len(axis.Values))
+		for _, combination := range combinations {
+			for _, value := range axis.Values {
+				c := maps.Clone(combination)
+				c[axis.Name] = value
+				next = append(next, c)
+			}
+		}
+		combinations = next
+	}
+
+	instances := make([]Step, 0, len(combinations))
+	for _, combination := range combinations {
+		instance := step
+		instance.Matrix = nil
+		instance.MatrixOf = step.ID
+		instance.MatrixValues = combination
+		instance.Params = maps.Clone(step.Params)
+		instance.DependsOn = append([]string(nil), step.DependsOn...)
+		instance.ID = fmt.Sprintf("%s[%s]", step.ID, matrixKey(step.Matrix, combination))
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+// matrixError returns a *MatrixError for step.
+func matrixError(step Step, format string, args ...interface{}) error {
+	return &MatrixError{StepID: step.ID, Err: fmt.Errorf(format, args...)}
+}
+
+// matrixKey returns the combination of a matrix instance as axis=value pairs in axis order,
+// e.g. "region=eu,env=prod".
+func matrixKey(matrix Matrix, combination map[string]interface{}) string {
+	pairs := make([]string, len(matrix))
+	for i, axis := range matrix {
+		pairs[i] = fmt.Sprintf("%s=%v", axis.Name, combination[axis.Name])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// matrixInstanceKey returns the combination part of a matrix instance's ID.
+func matrixInstanceKey(step Step) string {
+	return strings.TrimSuffix(strings.TrimPrefix(step.ID, step.MatrixOf+"["), "]")
+}
+
+// matrixGroups returns the IDs of the instances of every expanded matrix step, keyed by the
+// matrix step's ID.
+func matrixGroups(workflow *Workflow) map[string][]string {
+	groups := make(map[string][]string)
+	for _, step := range workflow.Steps {
+		if step.MatrixOf != "" {
+			groups[step.MatrixOf] = append(groups[step.MatrixOf], step.ID)
+		}
+	}
+	return groups
+}
+
+// validateMatrix checks that matrix steps have been expanded, since the engine runs
+// instances only.
+func validateMatrix(workflow *Workflow) error {
+	var errs []error
+	for _, step := range workflow.Steps {
+		if len(step.Matrix) > 0 {
+			errs = append(errs, fmt.Errorf("step %s: matrix is not expanded; call Workflow.ExpandMatrix before running the workflow", step.ID))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// matrixData returns the template data of expanded matrix steps, keyed by their IDs: the
+// combined status of their instances, and the outputs and errors of the instances keyed by
+// combination, e.g. {{ index .steps.deploy.output "region=eu,env=prod" }}.
+func matrixData(execution *Execution, workflow *Workflow) map[string]interface{} {
+	type group struct {
+		statuses []StepStatus
+		outputs  map[string]interface{}
+		errors   map[string]interface{}
+	}
+	groups := make(map[string]*group)
+	for _, step := range workflow.Steps {
+		if step.MatrixOf == "" {
+			continue
+		}
+		g, ok := groups[step.MatrixOf]
+		if !ok {
+			g = &group{outputs: make(map[string]interface{}), errors: make(map[string]interface{})}
+			groups[step.MatrixOf] = g
+		}
+		state, ok := execution.StepStates[step.ID]
+		if !ok {
+			g.statuses = append(g.statuses, StepStatusPending)
+			continue
+		}
+		g.statuses = append(g.statuses, state.Status)
+		key := matrixInstanceKey(step)
+		if state.Status == StepStatusCompleted {
+			g.outputs[key] = state.Output
+		}
+		if state.Error != "" {
+			g.errors[key] = state.Error
+		}
+	}
+
+	data := make(map[string]interface{}, len(groups))
+	for id, g := range groups {
+		data[id] = map[string]interface{}{
+			"status": string(combinedStatus(g.statuses)),
+			"output": g.outputs,
+			"error":  g.errors,
+		}
+	}
+	return data
+}
+
+// combinedStatus summarises the statuses of a matrix step's instances: failed if any
+// failed, completed once all completed or were skipped, and pending or skipped if all are.
+// Anything else is running.
+func combinedStatus(statuses []StepStatus) StepStatus {
+	counts := make(map[StepStatus]int)
+	for _, status := range statuses {
+		counts[status]++
+	}
+	switch {
+	case counts[StepStatusFailed] > 0:
+		return StepStatusFailed
+	case counts[StepStatusPending] == len(statuses):
+		return StepStatusPending
+	case counts[StepStatusSkipped] == len(statuses):
+		return StepStatusSkipped
+	case counts[StepStatusCompleted]+counts[StepStatusSkipped] == len(statuses):
+		return StepStatusCompleted
+	default:
+		return StepStatusRunning
+	}
+}
+
+// stepData returns the template data for step: buildTemplateData, plus .matrix for the
+// instances of a matrix step. The caller must hold r.mu.
+func (r *executionRun) stepData(step Step) map[string]interface{} {
+	return withMatrix(buildTemplateData(r.execution, r.workflow, r.inputs), step)
+}
+
+// withMatrix returns data with the combination of a matrix instance exposed as .matrix, or
+// data itself for other steps.
+func withMatrix(data map[string]interface{}, step Step) map[string]interface{} {
+	if step.MatrixValues == nil {
+		return data
+	}
+	matrixData := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		matrixData[k] = v
+	}
+	matrixData["matrix"] = step.MatrixValues
+	return matrixData
+}
@@ -0,0 +1,221 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func matrixWorkflow() *Workflow {
+	return &Workflow{
+		ID: "wf-matrix",
+		Steps: []Step{
+			{ID: "build", Tool: "sire:local/build"},
+			{
+				ID:   "deploy",
+				Tool: "sire:local/deploy",
+				Matrix: Matrix{
+					{Name: "region", Values: []interface{}{"eu", "us"}},
+					{Name: "env", Values: []interface{}{"staging", "prod"}},
+				},
+				Params: map[string]interface{}{"target": "{{ .matrix.region }}-{{ .matrix.env }}"},
+			},
+			{ID: "report", Tool: "sire:local/report", DependsOn: []string{"deploy"}},
+		},
+		Edges: []Edge{{From: "build", To: "deploy"}},
+	}
+}
+
+func TestWorkflow_ExpandMatrix(t *testing.T) {
+	workflow := matrixWorkflow()
+	if err := workflow.ExpandMatrix(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	instances := []string{
+		"deploy[region=eu,env=staging]",
+		"deploy[region=eu,env=prod]",
+		"deploy[region=us,env=staging]",
+		"deploy[region=us,env=prod]",
+	}
+	var ids []string
+	for _, step := range workflow.Steps {
+		ids = append(ids, step.ID)
+	}
+	if want := append(append([]string{"build"}, instances...), "report"); !reflect.DeepEqual(ids, want) {
+		t.Fatalf("expected steps %v, got %v", want, ids)
+	}
+
+	instance := workflow.Steps[2]
+	if instance.MatrixOf != "deploy" || instance.Matrix != nil {
+		t.Errorf("expected an instance of deploy without a matrix, got %+v", instance)
+	}
+	if want := map[string]interface{}{"region": "eu", "env": "prod"}; !reflect.DeepEqual(instance.MatrixValues, want) {
+		t.Errorf("expected matrix values %v, got %v", want, instance.MatrixValues)
+	}
+	if got := workflow.Steps[5].DependsOn; !reflect.DeepEqual(got, instances) {
+		t.Errorf("expected depends_on to name every instance, got %v", got)
+	}
+	var edges []string
+	for _, edge := range workflow.Edges {
+		edges = append(edges, edge.From+" -> "+edge.To)
+	}
+	if len(edges) != 4 || edges[1] != "build -> deploy[region=eu,env=prod]" {
+		t.Errorf("expected the edge to be expanded to every instance, got %v", edges)
+	}
+}
+
+func TestWorkflow_ExpandMatrix_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		matrix Matrix
+		want   string
+	}{
+		{"no values", Matrix{{Name: "region"}}, "matrix axis region has no values"},
+		{"duplicate axis", Matrix{{Name: "region", Values: []interface{}{"eu"}}, {Name: "region", Values: []interface{}{"us"}}}, "matrix axis region is declared twice"},
+		{"invalid name", Matrix{{Name: "the region", Values: []interface{}{"eu"}}}, `invalid matrix axis name "the region"`},
+		{"duplicate value", Matrix{{Name: "region", Values: []interface{}{"eu", "eu"}}}, "matrix axis region has the value eu twice"},
+		{"list value", Matrix{{Name: "region", Values: []interface{}{[]interface{}{"eu"}}}}, "values must be strings, numbers or booleans"},
+		{"delimiter in value", Matrix{{Name: "region", Values: []interface{}{"eu,us"}}}, `value "eu,us" cannot contain`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			workflow := &Workflow{ID: "wf", Steps: []Step{{ID: "deploy", Tool: "sire:local/deploy", Matrix: tc.matrix}}}
+			err := workflow.ExpandMatrix()
+			var matrixErr *MatrixError
+			if !errors.As(err, &matrixErr) || matrixErr.StepID != "deploy" || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected a *MatrixError for deploy containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+
+	workflow := &Workflow{ID: "wf", Steps: []Step{
+		{ID: "deploy", Tool: "sire:local/deploy", Matrix: Matrix{{Name: "env", Values: []interface{}{"prod"}}}},
+		{ID: "deploy[env=prod]", Tool: "sire:local/deploy"},
+	}}
+	if err := workflow.ExpandMatrix(); err == nil || !strings.Contains(err.Error(), "matrix instance deploy[env=prod] has the ID of another step") {
+		t.Errorf("expected an ID collision error, got %v", err)
+	}
+}
+
+func TestMatrix_UnmarshalYAML(t *testing.T) {
+	var step Step
+	if err := yaml.Unmarshal([]byte("id: deploy\nmatrix:\n  region: [eu, us]\n  replicas: [1, 3]\n"), &step); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Matrix{
+		{Name: "region", Values: []interface{}{"eu", "us"}},
+		{Name: "replicas", Values: []interface{}{1, 3}},
+	}
+	if !reflect.DeepEqual(step.Matrix, want) {
+		t.Errorf("expected axes in declaration order %v, got %v", want, step.Matrix)
+	}
+	if err := yaml.Unmarshal([]byte("matrix: [eu, us]\n"), &step); err == nil {
+		t.Error("expected an error for a matrix that is not a mapping")
+	}
+}
+
+func TestEngine_Execute_Matrix(t *testing.T) {
+	var mu sync.Mutex
+	targets := make(map[string]interface{})
+	var report map[string]interface{}
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch tool {
+			case "sire:local/deploy":
+				targets[params["target"].(string)] = true
+				return map[string]interface{}{"url": "https://" + params["target"].(string)}, nil
+			case "sire:local/report":
+				report = params
+			}
+			return map[string]interface{}{}, nil
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{})
+
+	workflow := matrixWorkflow()
+	workflow.Steps[2].Params = map[string]interface{}{
+		"status": "{{ .deploy.status }}",
+		"urls":   "{{ .steps.deploy.output }}",
+		"eu":     `{{ (index .steps.deploy.output "region=eu,env=prod").url }}`,
+	}
+	workflow.Steps[2].When = `steps["deploy[region=us,env=prod]"].status == "completed"`
+	if err := workflow.ExpandMatrix(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	execution := &Execution{ID: "exec-matrix-1", StepStates: make(map[string]*StepState)}
+
+	execResult, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if execResult.Status != ExecutionStatusCompleted {
+		t.Fatalf("expected the execution to complete, got %s", execResult.Status)
+	}
+	if len(targets) != 4 || targets["us-staging"] == nil {
+		t.Errorf("expected one dispatch per combination, got %v", targets)
+	}
+	if report == nil {
+		t.Fatal("expected the report step to run")
+	}
+	if report["status"] != "completed" || report["eu"] != "https://eu-prod" {
+		t.Errorf("expected the combined status and the eu/prod output, got %v", report)
+	}
+	urls, ok := report["urls"].(map[string]interface{})
+	if !ok || len(urls) != 4 {
+		t.Errorf("expected the outputs of every instance keyed by combination, got %#v", report["urls"])
+	}
+}
+
+func TestEngine_Execute_UnexpandedMatrix(t *testing.T) {
+	engine := NewEngine(&MockDispatcher{}, &MockStore{})
+	execution := &Execution{ID: "exec-matrix-2", StepStates: make(map[string]*StepState)}
+
+	_, err := engine.Execute(context.Background(), execution, matrixWorkflow(), nil)
+	if err == nil || !strings.Contains(err.Error(), "step deploy: matrix is not expanded") {
+		t.Errorf("expected an unexpanded matrix error, got %v", err)
+	}
+}
+
+func TestCombinedStatus(t *testing.T) {
+	for _, tc := range []struct {
+		statuses []StepStatus
+		want     StepStatus
+	}{
+		{[]StepStatus{StepStatusPending, StepStatusPending}, StepStatusPending},
+		{[]StepStatus{StepStatusCompleted, StepStatusPending}, StepStatusRunning},
+		{[]StepStatus{StepStatusCompleted, StepStatusSkipped}, StepStatusCompleted},
+		{[]StepStatus{StepStatusSkipped, StepStatusSkipped}, StepStatusSkipped},
+		{[]StepStatus{StepStatusCompleted, StepStatusFailed}, StepStatusFailed},
+	} {
+		if got := combinedStatus(tc.statuses); got != tc.want {
+			t.Errorf("combinedStatus(%v) = %s, want %s", tc.statuses, got, tc.want)
+		}
+	}
+}
+
+func TestValidateReferences_Matrix(t *testing.T) {
+	workflow := matrixWorkflow()
+	workflow.Steps[2].Params = map[string]interface{}{"urls": "{{ .steps.deploy.output }}"}
+	workflow.Steps = append(workflow.Steps, Step{ID: "audit", Tool: "sire:local/audit", Params: map[string]interface{}{"urls": "{{ .deploy.output }}"}})
+	if err := workflow.ExpandMatrix(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := ValidateReferences(workflow)
+	if err == nil {
+		t.Fatal("expected an error for the step that does not depend on deploy")
+	}
+	if want := `step audit: references step "deploy", which is not an ancestor`; !strings.Contains(err.Error(), want) {
+		t.Errorf("expected error %q to contain %q", err, want)
+	}
+	if errs := splitErrors(err); len(errs) != 1 {
+		t.Errorf("expected only the audit step to be reported, got %v", errs)
+	}
+}
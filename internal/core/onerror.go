@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// runFallback dispatches the fallback tool of a step that has failed for good. On success the
+// step completes with the fallback's output while keeping the original error in its state.
+// r.mu is released during the dispatch. The caller must hold r.mu.
+func (r *executionRun) runFallback(ctx context.Context, step Step, stepState *StepState) error {
+	// The failure is saved first, so the fallback's params can read .steps.<id>.error.
+	_ = r.save() // Attempt to save state
+	fallback := step
+	fallback.Tool = step.Fallback.Tool
+	fallback.Params = step.Fallback.Params
+	params, err := r.stepInputs(fallback, r.stepData(fallback))
+	if err != nil {
+		return fmt.Errorf("error resolving fallback params: %w", err)
+	}
+
+	r.mu.Unlock()
+	output, err := r.dispatch(ctx, fallback, params)
+	r.mu.Lock()
+	if err != nil {
+		if r.checkCancelled() {
+			stepState.Status = StepStatusCancelled
+		}
+		return err
+	}
+
+	stepState.Status = StepStatusCompleted
+	stepState.Output = output
+	stepState.UsedFallback = true
+	r.execution.CompletionOrder = append(r.execution.CompletionOrder, step.ID)
+	if err := r.save(); err != nil {
+		return fmt.Errorf("failed to save execution state after step %s: %w", step.ID, err)
+	}
+	return nil
+}
+
+// validateErrorHandling checks the on_error policies and fallbacks of the workflow's steps.
+func validateErrorHandling(workflow *Workflow) error {
+	var errs []error
+	for _, step := range workflow.Steps {
+		switch step.OnError {
+		case "", OnErrorFail, OnErrorContinue:
+			if step.Fallback != nil {
+				errs = append(errs, fmt.Errorf("step %s: fallback requires on_error: %s", step.ID, OnErrorFallback))
+			}
+		case OnErrorFallback:
+			if step.Fallback == nil || step.Fallback.Tool == "" {
+				errs = append(errs, fmt.Errorf("step %s: on_error: %s requires a fallback tool", step.ID, OnErrorFallback))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("step %s: unknown on_error %q (expected %s, %s or %s)", step.ID, step.OnError, OnErrorFail, OnErrorContinue, OnErrorFallback))
+		}
+	}
+	return errors.Join(errs...)
+}
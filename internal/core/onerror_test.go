@@ -0,0 +1,151 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEngine_Execute_OnErrorContinue(t *testing.T) {
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			switch tool {
+			case "sire:local/test.optional":
+				return nil, errors.New("cache unavailable")
+			case "sire:local/test.report":
+				return map[string]interface{}{"cache_error": params["cache_error"]}, nil
+			default:
+				return nil, fmt.Errorf("unknown tool: %s", tool)
+			}
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{})
+
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "warm_cache", Tool: "sire:local/test.optional", OnError: OnErrorContinue, Retry: &RetryPolicy{MaxAttempts: 1}},
+			{ID: "report", Tool: "sire:local/test.report", Params: map[string]interface{}{"cache_error": "{{ .warm_cache.error }}"}},
+		},
+		Edges: []Edge{{From: "warm_cache", To: "report"}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	execution, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if execution.Status != ExecutionStatusCompleted {
+		t.Errorf("expected status %q, got %q", ExecutionStatusCompleted, execution.Status)
+	}
+	if state := execution.StepStates["warm_cache"]; state.Status != StepStatusFailed || state.Error != "cache unavailable" {
+		t.Errorf("expected the failure to be recorded, got %+v", state)
+	}
+	if got := execution.StepStates["report"].Output["cache_error"]; got != "cache unavailable" {
+		t.Errorf("expected the dependant to read the error, got %v", got)
+	}
+
+	// A tolerated failure is final; resuming does not run it again.
+	if _, err := engine.Execute(context.Background(), execution, workflow, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := execution.StepStates["warm_cache"].Attempts; got != 1 {
+		t.Errorf("expected 1 attempt, got %d", got)
+	}
+}
+
+func TestEngine_Execute_OnErrorFallback(t *testing.T) {
+	failFallback := false
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			switch tool {
+			case "sire:local/test.primary":
+				return nil, errors.New("primary region down")
+			case "sire:local/test.secondary":
+				if failFallback {
+					return nil, errors.New("secondary region down")
+				}
+				return map[string]interface{}{"region": "secondary", "reason": params["reason"]}, nil
+			case "sire:local/test.use":
+				return map[string]interface{}{"region": params["region"]}, nil
+			default:
+				return nil, fmt.Errorf("unknown tool: %s", tool)
+			}
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{})
+
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{
+				ID:      "fetch",
+				Tool:    "sire:local/test.primary",
+				OnError: OnErrorFallback,
+				Fallback: &ToolCall{
+					Tool:   "sire:local/test.secondary",
+					Params: map[string]interface{}{"reason": "{{ .steps.fetch.error }}"},
+				},
+			},
+			{ID: "use", Tool: "sire:local/test.use", Params: map[string]interface{}{"region": "{{ .fetch.output.region }}"}},
+		},
+		Edges: []Edge{{From: "fetch", To: "use"}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	execution, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fetch := execution.StepStates["fetch"]
+	if fetch.Status != StepStatusCompleted || !fetch.UsedFallback {
+		t.Errorf("expected the step to complete through its fallback, got %+v", fetch)
+	}
+	if fetch.Error != "primary region down" {
+		t.Errorf("expected the original error to stay visible, got %q", fetch.Error)
+	}
+	if fetch.Output["reason"] != "primary region down" {
+		t.Errorf("expected the fallback to read the error, got %v", fetch.Output["reason"])
+	}
+	if got := execution.StepStates["use"].Output["region"]; got != "secondary" {
+		t.Errorf("expected the dependant to use the fallback output, got %v", got)
+	}
+
+	failFallback = true
+	execution = &Execution{ID: "test-execution-2", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+	execution, err = engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil || !strings.Contains(err.Error(), "fallback failed: secondary region down") {
+		t.Fatalf("expected the fallback error, got %v", err)
+	}
+	if execution.Status != ExecutionStatusFailed {
+		t.Errorf("expected status %q, got %q", ExecutionStatusFailed, execution.Status)
+	}
+	if got := execution.StepStates["fetch"].Error; !strings.Contains(got, "primary region down") {
+		t.Errorf("expected the original error to stay visible, got %q", got)
+	}
+}
+
+func TestEngine_Execute_InvalidOnError(t *testing.T) {
+	engine := NewEngine(&MockDispatcher{}, &MockStore{})
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "a", Tool: "sire:local/test.a", OnError: "ignore"},
+			{ID: "b", Tool: "sire:local/test.b", OnError: OnErrorFallback},
+			{ID: "c", Tool: "sire:local/test.c", Fallback: &ToolCall{Tool: "sire:local/test.d"}},
+		},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	_, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	for _, want := range []string{`step a: unknown on_error "ignore"`, "step b: on_error: fallback requires a fallback tool", "step c: fallback requires on_error: fallback"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %q", want, err.Error())
+		}
+	}
+}
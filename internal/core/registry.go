@@ -0,0 +1,62 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+)
+
+// WorkflowResolver looks up the workflow definition referenced by a sub-workflow step.
+type WorkflowResolver interface {
+	ResolveWorkflow(ref string) (*Workflow, error)
+}
+
+// WorkflowRegistry is a WorkflowResolver backed by registered workflows, keyed by ID.
+// References that are not registered are handed to loadFile, if one is set, as a file path.
+type WorkflowRegistry struct {
+	mu        sync.RWMutex
+	workflows map[string]*Workflow
+	loadFile  func(path string) (*Workflow, error)
+}
+
+// NewWorkflowRegistry creates a new WorkflowRegistry. loadFile may be nil to only resolve registered IDs.
+func NewWorkflowRegistry(loadFile func(path string) (*Workflow, error)) *WorkflowRegistry {
+	return &WorkflowRegistry{
+		workflows: make(map[string]*Workflow),
+		loadFile:  loadFile,
+	}
+}
+
+// Register registers a workflow under its ID.
+func (r *WorkflowRegistry) Register(workflow *Workflow) error {
+	if workflow.ID == "" {
+		return fmt.Errorf("workflow must have an ID to be registered")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.workflows[workflow.ID]; ok {
+		return fmt.Errorf("workflow %s already registered", workflow.ID)
+	}
+	r.workflows[workflow.ID] = workflow
+	return nil
+}
+
+// ResolveWorkflow returns the workflow registered under ref, or loads ref as a file path.
+func (r *WorkflowRegistry) ResolveWorkflow(ref string) (*Workflow, error) {
+	r.mu.RLock()
+	workflow, ok := r.workflows[ref]
+	r.mu.RUnlock()
+	if ok {
+		return workflow, nil
+	}
+
+	if r.loadFile == nil {
+		return nil, fmt.Errorf("workflow %q is not registered", ref)
+	}
+	workflow, err := r.loadFile(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workflow %q: %w", ref, err)
+	}
+	return workflow, nil
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"regexp"
+	"time"
+)
+
+// Backoff strategies for RetryPolicy.Backoff.
+const (
+	// BackoffFixed waits InitialInterval before every retry. It is the default.
+	BackoffFixed = "fixed"
+	// BackoffLinear waits InitialInterval times the number of attempts made so far.
+	BackoffLinear = "linear"
+	// BackoffExponential waits InitialInterval * Multiplier^(attempts-1).
+	BackoffExponential = "exponential"
+)
+
+const (
+	defaultFixedInterval = 5 * time.Second // Kept from the original fixed backoff
+	defaultBaseInterval  = 1 * time.Second // Base of linear and exponential backoff
+	defaultMultiplier    = 2.0
+)
+
+// defaultRandom is the jitter source used unless WithRandom overrides it.
+var defaultRandom = rand.Float64
+
+// Validate checks that the policy's backoff, durations, multiplier, jitter and error
+// patterns are well formed.
+func (p *RetryPolicy) Validate() error {
+	var errs []error
+	if p.MaxAttempts < 0 {
+		errs = append(errs, fmt.Errorf("max_attempts must not be negative, got %d", p.MaxAttempts))
+	}
+	switch p.Backoff {
+	case "", BackoffFixed, BackoffLinear, BackoffExponential:
+	default:
+		errs = append(errs, fmt.Errorf("unknown backoff %q (expected %s, %s or %s)", p.Backoff, BackoffFixed, BackoffLinear, BackoffExponential))
+	}
+	if _, err := parseInterval(p.InitialInterval); err != nil {
+		errs = append(errs, fmt.Errorf("initial_interval: %w", err))
+	}
+	if _, err := parseInterval(p.MaxInterval); err != nil {
+		errs = append(errs, fmt.Errorf("max_interval: %w", err))
+	}
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		errs = append(errs, fmt.Errorf("multiplier must be at least 1, got %g", p.Multiplier))
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		errs = append(errs, fmt.Errorf("jitter must be between 0 and 1, got %g", p.Jitter))
+	}
+	for _, pattern := range append(append([]string{}, p.RetryableErrors...), p.NonRetryableErrors...) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid error pattern %q: %w", pattern, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// delay returns how long to wait before retrying after the given number of attempts
+// (starting at 1). The interval grows according to Backoff, is capped at MaxInterval and
+// then shortened by a random fraction of up to Jitter, so the cap is never exceeded.
+// random must return a value in [0, 1).
+func (p *RetryPolicy) delay(attempts int, random func() float64) time.Duration {
+	initial, _ := parseInterval(p.InitialInterval) // Validated when the execution starts
+	maxInterval, _ := parseInterval(p.MaxInterval)
+	attempts = max(attempts, 1)
+
+	var d float64
+	switch p.Backoff {
+	case BackoffExponential:
+		if initial == 0 {
+			initial = defaultBaseInterval
+		}
+		multiplier := p.Multiplier
+		if multiplier == 0 {
+			multiplier = defaultMultiplier
+		}
+		d = float64(initial) * math.Pow(multiplier, float64(attempts-1))
+	case BackoffLinear:
+		if initial == 0 {
+			initial = defaultBaseInterval
+		}
+		d = float64(initial) * float64(attempts)
+	default:
+		if initial == 0 {
+			initial = defaultFixedInterval
+		}
+		d = float64(initial)
+	}
+
+	if maxInterval > 0 && d > float64(maxInterval) {
+		d = float64(maxInterval)
+	}
+	if d > math.MaxInt64 {
+		d = math.MaxInt64
+	}
+	if p.Jitter > 0 {
+		d -= d * p.Jitter * random()
+	}
+	return time.Duration(d)
+}
+
+// retryable reports whether a failure may be retried under the policy. Each pattern is
+// matched against the error code (e.g. "timeout") and as a regular expression against
+// the error message. Non-retryable patterns win; when RetryableErrors is set, only
+// matching errors are retried.
+func (p *RetryPolicy) retryable(err error, code string) bool {
+	if matchesError(p.NonRetryableErrors, err, code) {
+		return false
+	}
+	return len(p.RetryableErrors) == 0 || matchesError(p.RetryableErrors, err, code)
+}
+
+// matchesError reports whether any of the patterns matches the error code or message.
+func matchesError(patterns []string, err error, code string) bool {
+	for _, pattern := range patterns {
+		if code != "" && pattern == code {
+			return true
+		}
+		if re, compileErr := regexp.Compile(pattern); compileErr == nil && re.MatchString(err.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseInterval parses an optional, non-negative retry interval. An empty value means unset.
+func parseInterval(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", value, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid duration %q: must not be negative", value)
+	}
+	return d, nil
+}
+
+// retryPolicyFor returns the retry policy that applies to step: its own, or the
+// workflow's default. It returns nil when the step is not retried.
+func retryPolicyFor(workflow *Workflow, step Step) *RetryPolicy {
+	if step.Retry != nil {
+		return step.Retry
+	}
+	return workflow.Retry
+}
+
+// validateRetryPolicies checks the workflow's default retry policy and those of its steps.
+func validateRetryPolicies(workflow *Workflow) error {
+	var errs []error
+	if workflow.Retry != nil {
+		if err := workflow.Retry.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("workflow %s retry: %w", workflow.ID, err))
+		}
+	}
+	for _, step := range workflow.Steps {
+		if step.Retry == nil {
+			continue
+		}
+		if err := step.Retry.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("step %s retry: %w", step.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sleepUntil blocks until t, or returns early with the context's error.
+func sleepUntil(ctx context.Context, t time.Time) error {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
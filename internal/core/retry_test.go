@@ -0,0 +1,276 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	noJitter := func() float64 { return 0 }
+
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		want   []time.Duration // Delays after attempts 1, 2, 3, ...
+	}{
+		{
+			name:   "fixed default",
+			policy: RetryPolicy{},
+			want:   []time.Duration{5 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:   "fixed interval",
+			policy: RetryPolicy{Backoff: BackoffFixed, InitialInterval: "250ms"},
+			want:   []time.Duration{250 * time.Millisecond, 250 * time.Millisecond},
+		},
+		{
+			name:   "linear",
+			policy: RetryPolicy{Backoff: BackoffLinear, InitialInterval: "2s"},
+			want:   []time.Duration{2 * time.Second, 4 * time.Second, 6 * time.Second},
+		},
+		{
+			name:   "exponential defaults",
+			policy: RetryPolicy{Backoff: BackoffExponential},
+			want:   []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second},
+		},
+		{
+			name:   "exponential with multiplier and cap",
+			policy: RetryPolicy{Backoff: BackoffExponential, InitialInterval: "100ms", Multiplier: 3, MaxInterval: "1s"},
+			want:   []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second, time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, want := range tt.want {
+				if got := tt.policy.delay(i+1, noJitter); got != want {
+					t.Errorf("attempt %d: expected %s, got %s", i+1, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_DelayJitter(t *testing.T) {
+	policy := RetryPolicy{Backoff: BackoffExponential, InitialInterval: "1s", MaxInterval: "4s", Jitter: 0.5}
+
+	// Jitter takes up to Jitter of the interval off, so the cap is never exceeded.
+	if got := policy.delay(2, func() float64 { return 0.5 }); got != 1500*time.Millisecond {
+		t.Errorf("expected 1.5s, got %s", got)
+	}
+	if got := policy.delay(10, func() float64 { return 0 }); got != 4*time.Second {
+		t.Errorf("expected the cap of 4s, got %s", got)
+	}
+	if got := policy.delay(10, func() float64 { return 0.999 }); got <= 2*time.Second || got > 4*time.Second {
+		t.Errorf("expected a delay in (2s, 4s], got %s", got)
+	}
+}
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	timeout := fmt.Errorf("%w after 1s", ErrStepTimeout)
+	refused := errors.New("dial tcp: connection refused")
+	invalid := errors.New("400 bad request: invalid email")
+
+	policy := RetryPolicy{
+		RetryableErrors:    []string{ErrorCodeTimeout, "connection refused", "bad request"},
+		NonRetryableErrors: []string{"invalid email"},
+	}
+	if !policy.retryable(timeout, errorCode(timeout)) {
+		t.Errorf("expected a timeout to match its error code")
+	}
+	if !policy.retryable(refused, errorCode(refused)) {
+		t.Errorf("expected a message pattern to match")
+	}
+	if policy.retryable(invalid, errorCode(invalid)) {
+		t.Errorf("expected non-retryable patterns to take precedence")
+	}
+	if policy.retryable(errors.New("disk full"), "") {
+		t.Errorf("expected errors outside RetryableErrors not to be retried")
+	}
+	if !(&RetryPolicy{}).retryable(errors.New("disk full"), "") {
+		t.Errorf("expected every error to be retryable without filters")
+	}
+}
+
+func TestRetryPolicy_Validate(t *testing.T) {
+	if err := (&RetryPolicy{MaxAttempts: 3, Backoff: BackoffExponential, InitialInterval: "1s", MaxInterval: "1m", Multiplier: 1.5, Jitter: 0.2}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err := (&RetryPolicy{
+		Backoff:         "random",
+		InitialInterval: "soon",
+		Multiplier:      0.5,
+		Jitter:          2,
+		RetryableErrors: []string{"("},
+	}).Validate()
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	for _, want := range []string{"unknown backoff", "initial_interval", "multiplier", "jitter", "invalid error pattern"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %q", want, err.Error())
+		}
+	}
+}
+
+func TestEngine_Execute_WorkflowRetryPolicy(t *testing.T) {
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			if tool == "sire:local/test.invalid" {
+				return nil, errors.New("validation failed")
+			}
+			return nil, errors.New("service unavailable")
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{}, WithRandom(func() float64 { return 0 }))
+
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Retry: &RetryPolicy{
+			MaxAttempts:        3,
+			Backoff:            BackoffExponential,
+			InitialInterval:    "1m",
+			NonRetryableErrors: []string{"^validation"},
+		},
+		Steps: []Step{
+			{ID: "inherits", Tool: "sire:local/test.flaky"},
+			{ID: "own", Tool: "sire:local/test.flaky", Retry: &RetryPolicy{MaxAttempts: 1}},
+			{ID: "invalid", Tool: "sire:local/test.invalid"},
+		},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	start := time.Now()
+	if _, err := engine.Execute(context.Background(), execution, workflow, nil); err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+
+	inherits := execution.StepStates["inherits"]
+	if inherits.Status != StepStatusRetrying {
+		t.Errorf("expected the workflow retry policy to be inherited, got %s", inherits.Status)
+	}
+	if wait := inherits.NextAttempt.Sub(start); wait < time.Minute || wait > time.Minute+time.Second {
+		t.Errorf("expected the next attempt in about 1m, got %s", wait)
+	}
+	if got := execution.StepStates["own"].Status; got != StepStatusFailed {
+		t.Errorf("expected the step's own policy to take precedence, got %s", got)
+	}
+	if got := execution.StepStates["invalid"].Status; got != StepStatusFailed {
+		t.Errorf("expected a non-retryable error to fail the step, got %s", got)
+	}
+}
+
+func TestEngine_Execute_InvalidRetryPolicy(t *testing.T) {
+	engine := NewEngine(&MockDispatcher{}, &MockStore{})
+	workflow := &Workflow{
+		ID:    "test-workflow",
+		Steps: []Step{{ID: "step1", Tool: "sire:local/test.step", Retry: &RetryPolicy{MaxAttempts: 2, Jitter: 1.5}}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	_, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil || !strings.Contains(err.Error(), "step step1 retry") {
+		t.Fatalf("expected an invalid retry policy error, got %v", err)
+	}
+}
+
+func TestEngine_Execute_RetryModeWait(t *testing.T) {
+	attempts := 0
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			if tool == "sire:local/test.flaky" {
+				attempts++
+				if attempts < 3 {
+					return nil, fmt.Errorf("simulated transient error on attempt %d", attempts)
+				}
+			}
+			return map[string]interface{}{"tool": tool}, nil
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{}, WithRetryMode(RetryModeWait))
+
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "flaky", Tool: "sire:local/test.flaky", Retry: &RetryPolicy{MaxAttempts: 3, InitialInterval: "10ms"}},
+			{ID: "other", Tool: "sire:local/test.other"},
+			{ID: "after", Tool: "sire:local/test.after"},
+		},
+		Edges: []Edge{{From: "flaky", To: "after"}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	execution, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if execution.Status != ExecutionStatusCompleted {
+		t.Errorf("expected status %q, got %q", ExecutionStatusCompleted, execution.Status)
+	}
+	if got := execution.StepStates["flaky"].Attempts; got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+	if got := execution.StepStates["after"].Status; got != StepStatusCompleted {
+		t.Errorf("expected the dependant to run after the retry, got %s", got)
+	}
+}
+
+func TestEngine_Execute_RetryModeWaitCancelled(t *testing.T) {
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			return nil, errors.New("service unavailable")
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{}, WithRetryMode(RetryModeWait))
+
+	workflow := &Workflow{
+		ID:    "test-workflow",
+		Steps: []Step{{ID: "flaky", Tool: "sire:local/test.flaky", Retry: &RetryPolicy{MaxAttempts: 3, InitialInterval: "1h"}}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	execution, err := engine.Execute(ctx, execution, workflow, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the wait to end with the context, got %v", err)
+	}
+	// The execution is left for the agent to resume.
+	if execution.Status != ExecutionStatusRunning {
+		t.Errorf("expected status %q, got %q", ExecutionStatusRunning, execution.Status)
+	}
+	if got := execution.StepStates["flaky"].Status; got != StepStatusRetrying {
+		t.Errorf("expected status %q, got %q", StepStatusRetrying, got)
+	}
+}
+
+func TestEngine_Execute_NoRetryPastDeadline(t *testing.T) {
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			return nil, errors.New("service unavailable")
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{}, WithRetryMode(RetryModeWait))
+
+	workflow := &Workflow{
+		ID:      "test-workflow",
+		Timeout: "1m",
+		Steps:   []Step{{ID: "flaky", Tool: "sire:local/test.flaky", Retry: &RetryPolicy{MaxAttempts: 3, InitialInterval: "1h"}}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	execution, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if execution.Status != ExecutionStatusFailed {
+		t.Errorf("expected status %q, got %q", ExecutionStatusFailed, execution.Status)
+	}
+	if got := execution.StepStates["flaky"].Attempts; got != 1 {
+		t.Errorf("expected no retry due after the deadline, got %d attempts", got)
+	}
+}
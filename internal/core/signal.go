@@ -0,0 +1,200 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrExecutionWaiting is returned by Execute for an execution parked until one of its
+// steps receives a signal, stops waiting for it, or has its timer fire.
+var ErrExecutionWaiting = errors.New("execution is waiting")
+
+// Signal delivers a signal to a step of an execution. The payload is stored durably and
+// becomes the step output the next time the execution runs, typically when the agent
+// resumes it.
+func (e *Engine) Signal(executionID, stepID string, payload map[string]interface{}) error {
+	if e.store == nil {
+		return fmt.Errorf("cannot signal execution %s: no store is configured", executionID)
+	}
+	execution, err := e.store.LoadExecution(executionID)
+	if err != nil {
+		return fmt.Errorf("failed to load execution %s: %w", executionID, err)
+	}
+	switch execution.Status {
+	case ExecutionStatusCompleted, ExecutionStatusCancelled:
+		return fmt.Errorf("execution %s is already %s", executionID, execution.Status)
+	}
+	if execution.Workflow == nil {
+		return fmt.Errorf("execution %s has no workflow definition", executionID)
+	}
+
+	var step *Step
+	for i := range execution.Workflow.Steps {
+		if execution.Workflow.Steps[i].ID == stepID {
+			step = &execution.Workflow.Steps[i]
+			break
+		}
+	}
+	if step == nil {
+		return fmt.Errorf("execution %s has no step %s", executionID, stepID)
+	}
+	if step.Signal == nil {
+		return fmt.Errorf("step %s does not wait for a signal", stepID)
+	}
+	if stepState, ok := execution.StepStates[stepID]; ok {
+		switch stepState.Status {
+		case StepStatusCompleted, StepStatusFailed, StepStatusSkipped, StepStatusCancelled:
+			return fmt.Errorf("step %s is already %s", stepID, stepState.Status)
+		}
+	}
+	_, signalled, err := e.store.LoadSignal(executionID, stepID)
+	if err != nil {
+		return err
+	}
+	if signalled {
+		return fmt.Errorf("step %s has already been signalled", stepID)
+	}
+
+	if payload == nil {
+		payload = make(map[string]interface{})
+	}
+	if err := e.store.SaveSignal(executionID, stepID, payload); err != nil {
+		return fmt.Errorf("failed to save signal for step %s: %w", stepID, err)
+	}
+	return nil
+}
+
+// runSignal runs a signal step. The step completes with the payload of its signal once one
+// has been delivered; until then it is marked waiting, and fails with the timeout error
+// code if its timeout expires first.
+func (r *executionRun) runSignal(ctx context.Context, step Step, stepState *StepState) error {
+	payload, signalled, err := r.loadSignal(step.ID)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error loading signal for step %s: %w", step.ID, err)
+	}
+
+	now := time.Now()
+	switch {
+	case signalled:
+		stepState.Attempts++
+		return r.completeStep(step, stepState, payload)
+	case ctx.Err() != nil:
+		stepState.Attempts++
+		return r.recordFailure(ctx, step, stepState, ErrDeadlineExceeded)
+	case stepState.Status == StepStatusWaiting && !stepState.WakeAt.IsZero() && !now.Before(stepState.WakeAt):
+		stepState.Attempts++
+		return r.recordFailure(ctx, step, stepState, fmt.Errorf("%w: no signal received within %s", ErrStepTimeout, step.Signal.Timeout))
+	case stepState.Status != StepStatusWaiting:
+		// The timeout starts when the step starts waiting, and again on every retry.
+		stepState.Status = StepStatusWaiting
+		stepState.WakeAt = time.Time{}
+		if timeout, _ := parseTimeout(step.Signal.Timeout); timeout > 0 { // Validated when the execution starts
+			stepState.WakeAt = now.Add(timeout)
+		}
+	}
+	if err := r.save(); err != nil {
+		return fmt.Errorf("failed to save execution state after step %s: %w", step.ID, err)
+	}
+	return nil
+}
+
+// loadSignal loads the signal delivered to a step of this execution, if any.
+func (r *executionRun) loadSignal(stepID string) (map[string]interface{}, bool, error) {
+	if r.engine.store == nil {
+		return nil, false, nil
+	}
+	return r.engine.store.LoadSignal(r.execution.ID, stepID)
+}
+
+// wokenSteps returns the waiting steps that can make progress, in declaration order. Once
+// the execution deadline has passed, every waiting step is woken so that it fails. The
+// caller must hold r.mu.
+func (r *executionRun) wokenSteps(now time.Time) []string {
+	expired := !r.execution.Deadline.IsZero() && !now.Before(r.execution.Deadline)
+	var woken []string
+	for _, step := range r.workflow.Steps {
+		if stepState, ok := r.execution.StepStates[step.ID]; ok && stepState.Status == StepStatusWaiting &&
+			(expired || stepWoken(r.engine.store, r.execution, step.ID, stepState, now)) {
+			woken = append(woken, step.ID)
+		}
+	}
+	return woken
+}
+
+// parkWaiting marks the execution as waiting if any of its steps is waiting for a signal, and
+// reports whether it did. The caller must hold r.mu.
+func (r *executionRun) parkWaiting() bool {
+	for _, stepState := range r.execution.StepStates {
+		if stepState.Status == StepStatusWaiting {
+			r.execution.Status = ExecutionStatusWaiting
+			_ = r.save() // Attempt to save state
+			return true
+		}
+	}
+	return false
+}
+
+// Woken reports whether a waiting execution can make progress: one of its waiting steps has
+// been signalled, has timed out or has had its timer fire, the execution deadline has
+// passed, or a step is due for a retry. Executions that are not waiting are always reported as woken.
+func Woken(store Store, execution *Execution, now time.Time) bool {
+	if execution.Status != ExecutionStatusWaiting {
+		return true
+	}
+	if !execution.Deadline.IsZero() && !now.Before(execution.Deadline) {
+		return true
+	}
+	for stepID, stepState := range execution.StepStates {
+		switch stepState.Status {
+		case StepStatusWaiting:
+			if stepWoken(store, execution, stepID, stepState, now) {
+				return true
+			}
+		case StepStatusRetrying:
+			if !now.Before(stepState.NextAttempt) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stepWoken reports whether a waiting step can make progress: it has been signalled, its
+// WakeAt has passed, or it runs a sub-workflow whose child execution has been woken.
+func stepWoken(store Store, execution *Execution, stepID string, stepState *StepState, now time.Time) bool {
+	if !stepState.WakeAt.IsZero() && !now.Before(stepState.WakeAt) {
+		return true
+	}
+	if store == nil {
+		return false
+	}
+	if stepState.ChildExecutionID != "" {
+		child, err := store.LoadExecution(stepState.ChildExecutionID)
+		return err == nil && child.Status == ExecutionStatusWaiting && Woken(store, child, now)
+	}
+	_, signalled, err := store.LoadSignal(execution.ID, stepID)
+	return err == nil && signalled
+}
+
+// validateSignals checks that signal steps do not also dispatch a tool, and that their
+// timeouts are valid durations.
+func validateSignals(workflow *Workflow) error {
+	var errs []error
+	for _, step := range workflow.Steps {
+		if step.Signal == nil {
+			continue
+		}
+		if step.Tool != "" || step.Foreach != "" || step.Workflow != "" || step.Fallback != nil {
+			errs = append(errs, fmt.Errorf("step %s: signal steps cannot set tool, foreach, workflow or fallback", step.ID))
+		}
+		if _, err := parseTimeout(step.Signal.Timeout); err != nil {
+			errs = append(errs, fmt.Errorf("step %s: signal %w", step.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
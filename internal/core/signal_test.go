@@ -0,0 +1,162 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEngine_Execute_SignalStep(t *testing.T) {
+	var deployParams map[string]interface{}
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			if tool == "sire:local/test.deploy" {
+				deployParams = params
+			}
+			return map[string]interface{}{"done": true}, nil
+		},
+	}
+	store := &MockStore{}
+	engine := NewEngine(dispatcher, store, WithRetryMode(RetryModeWait))
+
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "build", Tool: "sire:local/test.build"},
+			{ID: "approve", Signal: &SignalSpec{}},
+			{ID: "deploy", Tool: "sire:local/test.deploy", Params: map[string]interface{}{"approver": "{{ .steps.approve.output.approver }}"}},
+		},
+		Edges: []Edge{{From: "build", To: "approve"}, {From: "approve", To: "deploy"}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, Workflow: workflow, StepStates: make(map[string]*StepState)}
+
+	_, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if !errors.Is(err, ErrExecutionWaiting) {
+		t.Fatalf("expected ErrExecutionWaiting, got %v", err)
+	}
+	if execution.Status != ExecutionStatusWaiting {
+		t.Errorf("expected status %q, got %q", ExecutionStatusWaiting, execution.Status)
+	}
+	if approve := execution.StepStates["approve"]; approve.Status != StepStatusWaiting || !approve.WakeAt.IsZero() {
+		t.Errorf("expected the signal step to wait indefinitely, got %+v", approve)
+	}
+	if _, ok := execution.StepStates["deploy"]; ok {
+		t.Errorf("expected no step to start before the signal")
+	}
+	if Woken(store, execution, time.Now()) {
+		t.Errorf("expected the execution to stay parked before the signal")
+	}
+
+	if err := engine.Signal(execution.ID, "approve", map[string]interface{}{"approver": "alice"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := engine.Signal(execution.ID, "approve", nil); err == nil {
+		t.Errorf("expected an error signalling a step twice, got none")
+	}
+	if !Woken(store, execution, time.Now()) {
+		t.Errorf("expected the execution to be woken by the signal")
+	}
+
+	if _, err := engine.Execute(context.Background(), execution, workflow, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if execution.Status != ExecutionStatusCompleted {
+		t.Errorf("expected status %q, got %q", ExecutionStatusCompleted, execution.Status)
+	}
+	if approve := execution.StepStates["approve"]; approve.Status != StepStatusCompleted || approve.Output["approver"] != "alice" || approve.Attempts != 1 {
+		t.Errorf("expected the signal payload as the step output, got %+v", approve)
+	}
+	if deployParams["approver"] != "alice" {
+		t.Errorf("expected the next step to see the payload, got params %v", deployParams)
+	}
+}
+
+func TestEngine_Execute_SignalTimeout(t *testing.T) {
+	store := &MockStore{}
+	engine := NewEngine(&MockDispatcher{}, store)
+
+	workflow := &Workflow{
+		ID:    "test-workflow",
+		Steps: []Step{{ID: "approve", Signal: &SignalSpec{Timeout: "20ms"}}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, Workflow: workflow, StepStates: make(map[string]*StepState)}
+
+	if _, err := engine.Execute(context.Background(), execution, workflow, nil); !errors.Is(err, ErrExecutionWaiting) {
+		t.Fatalf("expected ErrExecutionWaiting, got %v", err)
+	}
+	if execution.StepStates["approve"].WakeAt.IsZero() {
+		t.Fatalf("expected the signal step to record when it stops waiting")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !Woken(store, execution, time.Now()) {
+		t.Fatalf("expected the execution to be woken by the timeout")
+	}
+	if _, err := engine.Execute(context.Background(), execution, workflow, nil); !errors.Is(err, ErrStepTimeout) {
+		t.Fatalf("expected ErrStepTimeout, got %v", err)
+	}
+	if execution.Status != ExecutionStatusFailed {
+		t.Errorf("expected status %q, got %q", ExecutionStatusFailed, execution.Status)
+	}
+	if approve := execution.StepStates["approve"]; approve.Status != StepStatusFailed || approve.ErrorCode != ErrorCodeTimeout {
+		t.Errorf("expected the signal step to fail with a timeout, got %+v", approve)
+	}
+	if err := engine.Signal(execution.ID, "approve", nil); err == nil {
+		t.Errorf("expected an error signalling a step that timed out, got none")
+	}
+}
+
+func TestEngine_Signal_Errors(t *testing.T) {
+	store := &MockStore{}
+	engine := NewEngine(&MockDispatcher{}, store)
+
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "build", Tool: "sire:local/test.build"},
+			{ID: "approve", Signal: &SignalSpec{}},
+		},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, Workflow: workflow, Status: ExecutionStatusWaiting, StepStates: make(map[string]*StepState)}
+	if err := store.SaveExecution(execution); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := engine.Signal("missing", "approve", nil); err == nil {
+		t.Errorf("expected an error for an unknown execution, got none")
+	}
+	if err := engine.Signal(execution.ID, "missing", nil); err == nil {
+		t.Errorf("expected an error for an unknown step, got none")
+	}
+	if err := engine.Signal(execution.ID, "build", nil); err == nil {
+		t.Errorf("expected an error for a step that does not wait for a signal, got none")
+	}
+	if err := NewEngine(&MockDispatcher{}, nil).Signal(execution.ID, "approve", nil); err == nil {
+		t.Errorf("expected an error without a store, got none")
+	}
+}
+
+func TestValidateSignals(t *testing.T) {
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "ok", Signal: &SignalSpec{Timeout: "1h"}},
+			{ID: "tool", Tool: "sire:local/test.build", Signal: &SignalSpec{}},
+			{ID: "timeout", Signal: &SignalSpec{Timeout: "soon"}},
+		},
+	}
+	err := validateSignals(workflow)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	for _, stepID := range []string{"step tool:", "step timeout:"} {
+		if !strings.Contains(err.Error(), stepID) {
+			t.Errorf("expected error %q to mention %q", err, stepID)
+		}
+	}
+	if strings.Contains(err.Error(), "step ok:") {
+		t.Errorf("expected no error for a valid signal step, got %q", err)
+	}
+}
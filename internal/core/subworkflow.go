@@ -0,0 +1,171 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+)
+
+// maxSubWorkflowDepth bounds sub-workflow nesting so that a workflow that calls
+// itself fails instead of recursing forever.
+const maxSubWorkflowDepth = 32
+
+type subWorkflowDepthKey struct{}
+
+// runSubWorkflow runs step.Workflow as a child execution linked to this one. The child
+// is persisted on its own and reused when the step runs again, so a resumed parent
+// resumes the child instead of starting it over. The child's inputs are the step's resolved
+// params alone, whatever the wiring, since a child rejects inputs it does not declare. The
+// child records the parent's environment, which its workflow was loaded with. The step
+// output is the child's outputs.
+func (r *executionRun) runSubWorkflow(ctx context.Context, step Step, stepState *StepState) error {
+	depth, _ := ctx.Value(subWorkflowDepthKey{}).(int)
+	if depth >= maxSubWorkflowDepth {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		err := fmt.Errorf("sub-workflows are nested more than %d levels deep", maxSubWorkflowDepth)
+		r.failStep(stepState, err)
+		return fmt.Errorf("error executing step %s: %w", step.ID, err)
+	}
+
+	r.mu.Lock()
+	childInputs, err := ResolveParams(step.Params, r.stepData(step))
+	if err != nil {
+		r.failStep(stepState, err)
+		r.mu.Unlock()
+		return fmt.Errorf("error resolving params for step %s: %w", step.ID, err)
+	}
+	stepState.Attempts++
+	stepState.Status = StepStatusRunning
+	r.mu.Unlock()
+
+	child, childWorkflow, err := r.childExecution(step)
+	if err != nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.recordFailure(ctx, step, stepState, err)
+	}
+
+	r.mu.Lock()
+	stepState.ChildExecutionID = child.ID
+	_ = r.save() // Attempt to save state
+	r.mu.Unlock()
+
+	childCtx, cancel := stepContext(context.WithValue(ctx, subWorkflowDepthKey{}, depth+1), step)
+	defer cancel()
+	child, err = r.engine.Execute(childCtx, child, childWorkflow, childInputs)
+	if err != nil && childCtx.Err() != nil {
+		err = fmt.Errorf("%w: %w", timeoutError(childCtx, ctx, step), err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case err == nil:
+		return r.completeStep(step, stepState, child.Outputs)
+	case child.Status == ExecutionStatusFailed || child.Status == ExecutionStatusCancelled || r.execution.Status == ExecutionStatusCancelled:
+		return r.recordFailure(ctx, step, stepState, fmt.Errorf("sub-workflow execution %s failed: %w", child.ID, err))
+	case child.Status == ExecutionStatusWaiting:
+		// The child is parked until one of its steps is signalled; the step waits with it.
+		stepState.Attempts--
+		stepState.Status = StepStatusWaiting
+		stepState.WakeAt = wakeAt(child)
+		stepState.Error = ""
+		_ = r.save() // Attempt to save state
+		return nil
+	default:
+		// The child is still in progress, e.g. waiting for a retry; the step waits with it.
+		stepState.Attempts--
+		stepState.Status = StepStatusRetrying
+		stepState.NextAttempt = nextAttempt(child)
+		stepState.Error = err.Error()
+		_ = r.save() // Attempt to save state
+		return fmt.Errorf("error executing step %s: %w", step.ID, err)
+	}
+}
+
+// childExecution loads the child execution of a sub-workflow step, or creates it on the first run.
+func (r *executionRun) childExecution(step Step) (*Execution, *Workflow, error) {
+	r.mu.Lock()
+	childID := fmt.Sprintf("%s.%s", r.execution.ID, step.ID)
+	maxParallelism := r.execution.MaxParallelism
+	r.mu.Unlock()
+
+	if r.engine.store != nil {
+		if child, err := r.engine.store.LoadExecution(childID); err == nil && child.Workflow != nil {
+			return child, child.Workflow, nil
+		}
+	}
+
+	if r.engine.resolver == nil {
+		return nil, nil, fmt.Errorf("no workflow resolver configured for sub-workflow %q", step.Workflow)
+	}
+	childWorkflow, err := r.engine.resolver.ResolveWorkflow(step.Workflow)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	child := &Execution{
+		ID:                childID,
+		WorkflowID:        childWorkflow.ID,
+		Workflow:          childWorkflow,
+		Status:            ExecutionStatusRunning,
+		StepStates:        make(map[string]*StepState),
+		CreatedAt:         now,
+		UpdatedAt:         now,
+		ParentExecutionID: r.execution.ID,
+		ParentStepID:      step.ID,
+		MaxParallelism:    maxParallelism,
+		Env:               maps.Clone(r.execution.Env),
+	}
+	if r.engine.store != nil {
+		if err := r.engine.store.SaveExecution(child); err != nil {
+			return nil, nil, fmt.Errorf("failed to save sub-workflow execution: %w", err)
+		}
+	}
+	return child, childWorkflow, nil
+}
+
+// validateSubWorkflows checks that sub-workflow steps do not also dispatch a tool, which
+// they would never run, or fan out over items, which runs the tool instead of the workflow.
+func validateSubWorkflows(workflow *Workflow) error {
+	var errs []error
+	for _, step := range workflow.Steps {
+		if step.Workflow != "" && (step.Tool != "" || step.Foreach != "") {
+			errs = append(errs, fmt.Errorf("step %s: sub-workflow steps cannot set tool or foreach", step.ID))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// nextAttempt returns the earliest time at which a retrying step of the execution may run again.
+func nextAttempt(execution *Execution) time.Time {
+	var next time.Time
+	for _, stepState := range execution.StepStates {
+		if stepState.Status != StepStatusRetrying {
+			continue
+		}
+		if next.IsZero() || stepState.NextAttempt.Before(next) {
+			next = stepState.NextAttempt
+		}
+	}
+	return next
+}
+
+// wakeAt returns the earliest time at which a waiting step of the execution stops waiting, or
+// the zero time if all of them wait for a signal indefinitely.
+func wakeAt(execution *Execution) time.Time {
+	var wake time.Time
+	for _, stepState := range execution.StepStates {
+		if stepState.Status != StepStatusWaiting || stepState.WakeAt.IsZero() {
+			continue
+		}
+		if wake.IsZero() || stepState.WakeAt.Before(wake) {
+			wake = stepState.WakeAt
+		}
+	}
+	return wake
+}
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWorkflowRegistry(t *testing.T) {
+	loaded := &Workflow{ID: "from-file"}
+	registry := NewWorkflowRegistry(func(path string) (*Workflow, error) {
+		if path == "notify.yml" {
+			return loaded, nil
+		}
+		return nil, fmt.Errorf("no such file")
+	})
+	registered := &Workflow{ID: "notify"}
+	if err := registry.Register(registered); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := registry.Register(registered); err == nil {
+		t.Errorf("expected an error registering a duplicate, got none")
+	}
+
+	if wf, err := registry.ResolveWorkflow("notify"); err != nil || wf != registered {
+		t.Errorf("expected registered workflow, got %v (err: %v)", wf, err)
+	}
+	if wf, err := registry.ResolveWorkflow("notify.yml"); err != nil || wf != loaded {
+		t.Errorf("expected workflow loaded from file, got %v (err: %v)", wf, err)
+	}
+	if _, err := registry.ResolveWorkflow("missing.yml"); err == nil {
+		t.Errorf("expected an error, got none")
+	}
+}
+
+func TestEngine_Execute_SubWorkflow(t *testing.T) {
+	failSend := true
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			switch tool {
+			case "sire:local/order.create":
+				return map[string]interface{}{"order_id": "o-1"}, nil
+			case "sire:local/notify.format":
+				return map[string]interface{}{"text": "order " + params["order_id"].(string)}, nil
+			case "sire:local/notify.send":
+				if failSend {
+					return nil, fmt.Errorf("smtp unavailable")
+				}
+				return map[string]interface{}{"sent": params["text"]}, nil
+			default:
+				return nil, fmt.Errorf("unknown tool: %s", tool)
+			}
+		},
+	}
+
+	notify := &Workflow{
+		ID: "notify",
+		Steps: []Step{
+			{ID: "format", Tool: "sire:local/notify.format"},
+			{ID: "send", Tool: "sire:local/notify.send"},
+		},
+		Edges: []Edge{{From: "format", To: "send"}},
+	}
+	registry := NewWorkflowRegistry(nil)
+	if err := registry.Register(notify); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store := &MockStore{}
+	engine := NewEngine(dispatcher, store, WithWorkflowResolver(registry))
+
+	workflow := &Workflow{
+		ID: "orders",
+		Steps: []Step{
+			{ID: "create", Tool: "sire:local/order.create"},
+			{ID: "notify", Workflow: "notify", Params: map[string]interface{}{"order_id": "{{ .create.output.order_id }}"}},
+		},
+		Edges: []Edge{{From: "create", To: "notify"}},
+	}
+	execution := &Execution{ID: "exec-orders-1", StepStates: make(map[string]*StepState)}
+
+	// First run: the child fails on its second step.
+	execResult, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	childID := execResult.StepStates["notify"].ChildExecutionID
+	if childID == "" {
+		t.Fatalf("expected the step to record its child execution")
+	}
+	child, err := store.LoadExecution(childID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if child.ParentExecutionID != "exec-orders-1" || child.ParentStepID != "notify" {
+		t.Errorf("expected child to be linked to its parent, got %q/%q", child.ParentExecutionID, child.ParentStepID)
+	}
+	if child.Status != ExecutionStatusFailed || child.StepStates["format"].Status != StepStatusCompleted {
+		t.Errorf("expected child to fail after format completed, got %q", child.Status)
+	}
+	if !strings.Contains(execResult.StepStates["notify"].Error, "smtp unavailable") {
+		t.Errorf("expected step error to contain the child error, got %q", execResult.StepStates["notify"].Error)
+	}
+
+	// Resume: the same child execution is resumed, not started over.
+	failSend = false
+	execResult, err = engine.Execute(context.Background(), execResult, workflow, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if execResult.StepStates["notify"].ChildExecutionID != childID {
+		t.Errorf("expected child execution %q to be reused, got %q", childID, execResult.StepStates["notify"].ChildExecutionID)
+	}
+	if child.StepStates["format"].Attempts != 1 {
+		t.Errorf("expected completed child steps not to run again, got %d attempts", child.StepStates["format"].Attempts)
+	}
+	sendOutput, ok := execResult.StepStates["notify"].Output["send"].(map[string]interface{})
+	if !ok || sendOutput["sent"] != "order o-1" {
+		t.Errorf("expected the child outputs as step output, got %v", execResult.StepStates["notify"].Output)
+	}
+}
+
+func TestEngine_Execute_SubWorkflowInputs(t *testing.T) {
+	var sent map[string]interface{}
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			if tool == "sire:local/notify.send" {
+				sent = params
+			}
+			return map[string]interface{}{"order_id": "o-1"}, nil
+		},
+	}
+	notify := &Workflow{
+		ID:     "notify",
+		Inputs: []Input{{Name: "order_id", Type: InputTypeString, Required: true}, {Name: "channel", Default: "email"}},
+		Steps:  []Step{{ID: "send", Tool: "sire:local/notify.send"}},
+	}
+	registry := NewWorkflowRegistry(nil)
+	if err := registry.Register(notify); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store := &MockStore{}
+	engine := NewEngine(dispatcher, store, WithWorkflowResolver(registry))
+
+	// The parent's own inputs and the upstream outputs are not passed to the child, which
+	// would reject them as undeclared.
+	workflow := &Workflow{
+		ID:     "orders",
+		Inputs: []Input{{Name: "customer", Type: InputTypeString}},
+		Steps: []Step{
+			{ID: "create", Tool: "sire:local/order.create"},
+			{ID: "notify", Workflow: "notify", Params: map[string]interface{}{"order_id": "{{ .create.output.order_id }}"}},
+		},
+		Edges: []Edge{{From: "create", To: "notify"}},
+	}
+	execution := &Execution{ID: "exec-orders-2", StepStates: make(map[string]*StepState), Env: map[string]string{"SMTP_HOST": "mail.example.com"}}
+
+	execResult, err := engine.Execute(context.Background(), execution, workflow, map[string]interface{}{"customer": "c-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sent["order_id"] != "o-1" || sent["channel"] != "email" || sent["customer"] != nil {
+		t.Errorf("expected the child to receive the step params and its defaults only, got %v", sent)
+	}
+	child, err := store.LoadExecution(execResult.StepStates["notify"].ChildExecutionID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if child.Env["SMTP_HOST"] != "mail.example.com" {
+		t.Errorf("expected the child to record the parent's environment, got %v", child.Env)
+	}
+}
+
+func TestEngine_Execute_SubWorkflowRecursion(t *testing.T) {
+	registry := NewWorkflowRegistry(nil)
+	loop := &Workflow{ID: "loop", Steps: []Step{{ID: "again", Workflow: "loop"}}}
+	if err := registry.Register(loop); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	engine := NewEngine(&MockDispatcher{}, nil, WithWorkflowResolver(registry))
+
+	execution := &Execution{ID: "exec-loop-1", StepStates: make(map[string]*StepState)}
+	_, err := engine.Execute(context.Background(), execution, loop, nil)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if !strings.Contains(err.Error(), "nested more than") {
+		t.Errorf("expected a nesting error, got %q", err.Error())
+	}
+}
+
+func TestValidateSubWorkflows(t *testing.T) {
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "ok", Workflow: "notify"},
+			{ID: "tool", Workflow: "notify", Tool: "sire:local/test.send"},
+			{ID: "foreach", Workflow: "notify", Foreach: "inputs.orders"},
+			{ID: "items", Tool: "sire:local/test.send", Foreach: "inputs.orders"},
+		},
+	}
+	err := validateSubWorkflows(workflow)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	for _, stepID := range []string{"step tool:", "step foreach:"} {
+		if !strings.Contains(err.Error(), stepID) {
+			t.Errorf("expected error %q to mention %q", err, stepID)
+		}
+	}
+	for _, stepID := range []string{"step ok:", "step items:"} {
+		if strings.Contains(err.Error(), stepID) {
+			t.Errorf("expected no error for %q, got %q", stepID, err)
+		}
+	}
+}
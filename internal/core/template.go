@@ -0,0 +1,149 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// wholeExpression matches a string that consists of exactly one template action,
+// e.g. "{{ .fetch_data.output.records }}".
+var wholeExpression = regexp.MustCompile(`^\s*\{\{-?\s*(.*?)\s*-?\}\}\s*$`)
+
+// reservedTemplateKeys are top-level template names that step IDs cannot shadow.
+var reservedTemplateKeys = map[string]bool{
+	"inputs":    true,
+	"workflow":  true,
+	"execution": true,
+	"steps":     true,
+}
+
+// buildTemplateData assembles the data that step parameter templates are evaluated against:
+//   - .inputs: the workflow inputs
+//   - .workflow: id, name, execution_id and started_at of the running workflow
+//   - .execution: the execution id
+//   - .steps.<id>: status, output and error of every step that has a state
+//   - .steps.<id> of a matrix step: the combined status of its instances, and their
+//     outputs and errors keyed by combination; see matrixData
+//   - .<id>: shorthand for .steps.<id>, unless the ID clashes with one of the names above
+func buildTemplateData(execution *Execution, workflow *Workflow, inputs map[string]interface{}) map[string]interface{} {
+	if inputs == nil {
+		inputs = map[string]interface{}{}
+	}
+	steps := make(map[string]interface{}, len(execution.StepStates))
+	for stepID, stepState := range execution.StepStates {
+		steps[stepID] = map[string]interface{}{
+			"status": string(stepState.Status),
+			"output": stepState.Output,
+			"error":  stepState.Error,
+		}
+	}
+	for stepID, stepData := range matrixData(execution, workflow) {
+		if _, ok := steps[stepID]; !ok {
+			steps[stepID] = stepData
+		}
+	}
+
+	data := map[string]interface{}{
+		"inputs": inputs,
+		"workflow": map[string]interface{}{
+			"id":           workflow.ID,
+			"name":         workflow.Name,
+			"execution_id": execution.ID,
+			"started_at":   execution.CreatedAt.Format(time.RFC3339),
+		},
+		"execution": map[string]interface{}{
+			"id": execution.ID,
+		},
+		"steps": steps,
+	}
+	for stepID, stepData := range steps {
+		if !reservedTemplateKeys[stepID] {
+			data[stepID] = stepData
+		}
+	}
+	return data
+}
+
+// ResolveParams evaluates every template in params against data and returns the
+// resolved copy. Maps and lists are resolved recursively. A string that is a single
+// whole template action keeps the native type of its value; any other string containing
+// templates is rendered as text.
+func ResolveParams(params map[string]interface{}, data map[string]interface{}) (map[string]interface{}, error) {
+	if params == nil {
+		return nil, nil
+	}
+	resolved := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		value, err := resolveValue(v, data)
+		if err != nil {
+			return nil, fmt.Errorf("param %q: %w", k, err)
+		}
+		resolved[k] = value
+	}
+	return resolved, nil
+}
+
+func resolveValue(value interface{}, data map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return resolveString(v, data)
+	case map[string]interface{}:
+		return ResolveParams(v, data)
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			r, err := resolveValue(item, data)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+			resolved[i] = r
+		}
+		return resolved, nil
+	default:
+		return value, nil
+	}
+}
+
+func resolveString(s string, data map[string]interface{}) (interface{}, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+
+	if m := wholeExpression.FindStringSubmatch(s); m != nil && !strings.Contains(m[1], "{{") && !strings.Contains(m[1], "}}") {
+		return evalTemplateExpression(m[1], data)
+	}
+
+	tmpl, err := template.New("param").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %q: %w", s, err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return nil, fmt.Errorf("failed to render template %q: %w", s, err)
+	}
+	return sb.String(), nil
+}
+
+// evalTemplateExpression evaluates a single template pipeline and returns its value
+// without converting it to a string.
+func evalTemplateExpression(expression string, data map[string]interface{}) (interface{}, error) {
+	var captured interface{}
+	funcs := template.FuncMap{
+		"captureValue": func(v interface{}) string {
+			captured = v
+			return ""
+		},
+	}
+	tmpl, err := template.New("param").Funcs(funcs).Option("missingkey=error").Parse("{{ (" + expression + ") | captureValue }}")
+	if err != nil {
+		return nil, fmt.Errorf("invalid template expression %q: %w", expression, err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return nil, fmt.Errorf("failed to evaluate template expression %q: %w", expression, err)
+	}
+	return captured, nil
+}
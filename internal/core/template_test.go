@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveParams(t *testing.T) {
+	execution := &Execution{
+		ID: "exec-42",
+		StepStates: map[string]*StepState{
+			"fetch_data": {Status: StepStatusCompleted, Output: map[string]interface{}{
+				"records": []interface{}{1, 2, 3},
+				"count":   3,
+			}},
+			"node-1": {Status: StepStatusCompleted, Output: map[string]interface{}{"name": "first"}},
+		},
+	}
+	workflow := &Workflow{ID: "federated", Name: "Federated"}
+	data := buildTemplateData(execution, workflow, map[string]interface{}{"bucket": "results"})
+
+	params := map[string]interface{}{
+		"data":    "{{ .fetch_data.output.records }}",
+		"count":   "{{.steps.fetch_data.output.count}}",
+		"key":     "result-{{.workflow.id}}-{{ .execution.id }}.json",
+		"bucket":  "{{ .inputs.bucket }}",
+		"literal": "no templates here",
+		"number":  7,
+		"nested": map[string]interface{}{
+			"list": []interface{}{"{{ index .steps \"node-1\" \"output\" \"name\" }}", "static"},
+		},
+	}
+
+	resolved, err := ResolveParams(params, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(resolved["data"], []interface{}{1, 2, 3}) {
+		t.Errorf("expected data to keep its array type, got %#v", resolved["data"])
+	}
+	if resolved["count"] != 3 {
+		t.Errorf("expected count %d, got %#v", 3, resolved["count"])
+	}
+	if resolved["key"] != "result-federated-exec-42.json" {
+		t.Errorf("expected key %q, got %#v", "result-federated-exec-42.json", resolved["key"])
+	}
+	if resolved["bucket"] != "results" {
+		t.Errorf("expected bucket %q, got %#v", "results", resolved["bucket"])
+	}
+	if resolved["literal"] != "no templates here" {
+		t.Errorf("expected literal to be unchanged, got %#v", resolved["literal"])
+	}
+	if resolved["number"] != 7 {
+		t.Errorf("expected number to be unchanged, got %#v", resolved["number"])
+	}
+	nested := resolved["nested"].(map[string]interface{})
+	if !reflect.DeepEqual(nested["list"], []interface{}{"first", "static"}) {
+		t.Errorf("expected nested list to be resolved, got %#v", nested["list"])
+	}
+
+	// The original params must not be modified.
+	if params["data"] != "{{ .fetch_data.output.records }}" {
+		t.Errorf("expected params to be left untouched, got %#v", params["data"])
+	}
+}
+
+func TestResolveParams_MissingReference(t *testing.T) {
+	data := buildTemplateData(&Execution{ID: "exec-1", StepStates: map[string]*StepState{}}, &Workflow{ID: "wf"}, nil)
+
+	_, err := ResolveParams(map[string]interface{}{"data": "{{ .missing.output.records }}"}, data)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if !strings.Contains(err.Error(), `param "data"`) {
+		t.Errorf("expected error to name the param, got %q", err.Error())
+	}
+}
+
+func TestEngine_Execute_ResolvesTemplates(t *testing.T) {
+	var uploaded map[string]interface{}
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			switch tool {
+			case "sire:local/data.fetch":
+				return map[string]interface{}{"records": []interface{}{1.0, 2.0}}, nil
+			case "sire:local/s3.upload":
+				uploaded = params
+				return map[string]interface{}{}, nil
+			default:
+				return nil, fmt.Errorf("unknown tool: %s", tool)
+			}
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{})
+
+	workflow := &Workflow{
+		ID: "wf-templates",
+		Steps: []Step{
+			{ID: "fetch_data", Tool: "sire:local/data.fetch"},
+			{ID: "upload_result", Tool: "sire:local/s3.upload", Params: map[string]interface{}{
+				"key":  "result-{{ .workflow.id }}.json",
+				"body": "{{ .fetch_data.output.records }}",
+			}},
+		},
+		Edges: []Edge{{From: "fetch_data", To: "upload_result"}},
+	}
+	execution := &Execution{ID: "exec-templates-1", StepStates: make(map[string]*StepState)}
+
+	if _, err := engine.Execute(context.Background(), execution, workflow, map[string]interface{}{"env": "test"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if uploaded["key"] != "result-wf-templates.json" {
+		t.Errorf("expected key %q, got %#v", "result-wf-templates.json", uploaded["key"])
+	}
+	if !reflect.DeepEqual(uploaded["body"], []interface{}{1.0, 2.0}) {
+		t.Errorf("expected body to be the upstream records, got %#v", uploaded["body"])
+	}
+	if execution.Inputs["env"] != "test" {
+		t.Errorf("expected inputs to be recorded on the execution, got %v", execution.Inputs)
+	}
+}
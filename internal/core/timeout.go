@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Error codes recorded in StepState.ErrorCode.
+const (
+	// ErrorCodeTimeout marks a step attempt that exceeded the step's timeout.
+	ErrorCodeTimeout = "timeout"
+	// ErrorCodeDeadlineExceeded marks a step interrupted by the execution deadline.
+	ErrorCodeDeadlineExceeded = "deadline_exceeded"
+)
+
+var (
+	// ErrStepTimeout is returned for a step attempt that exceeded the step's timeout.
+	ErrStepTimeout = errors.New("step timed out")
+	// ErrDeadlineExceeded is returned for a step interrupted by the execution deadline.
+	ErrDeadlineExceeded = errors.New("execution deadline exceeded")
+)
+
+// errorCode classifies a step error into one of the ErrorCode constants, or "" for other errors.
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrStepTimeout):
+		return ErrorCodeTimeout
+	case errors.Is(err, ErrDeadlineExceeded):
+		return ErrorCodeDeadlineExceeded
+	default:
+		return ""
+	}
+}
+
+// parseTimeout parses an optional timeout duration. An empty value means no timeout.
+func parseTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q: %w", value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q: must be positive", value)
+	}
+	return d, nil
+}
+
+// validateTimeouts checks that the workflow, step and hook timeouts are valid durations.
+func validateTimeouts(workflow *Workflow) error {
+	if _, err := parseTimeout(workflow.Timeout); err != nil {
+		return fmt.Errorf("workflow %s: %w", workflow.ID, err)
+	}
+	for _, step := range append(append([]Step{}, workflow.Steps...), workflow.hooks()...) {
+		if _, err := parseTimeout(step.Timeout); err != nil {
+			return fmt.Errorf("step %s: %w", step.ID, err)
+		}
+	}
+	return nil
+}
+
+// stepContext derives the context for one attempt of a step, bounded by the step's timeout.
+func stepContext(ctx context.Context, step Step) (context.Context, context.CancelFunc) {
+	timeout, _ := parseTimeout(step.Timeout) // Validated when the execution starts
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// timeoutError explains why ctx ended: the step timeout, the execution deadline, or cancellation.
+func timeoutError(ctx, executionCtx context.Context, step Step) error {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ctx.Err()
+	}
+	if executionCtx.Err() != nil {
+		return ErrDeadlineExceeded
+	}
+	return fmt.Errorf("%w after %s", ErrStepTimeout, step.Timeout)
+}
+
+// pastDeadline reports whether the execution deadline, if any, has passed at now.
+func (r *executionRun) pastDeadline(now time.Time) bool {
+	return !r.execution.Deadline.IsZero() && !now.Before(r.execution.Deadline)
+}
+
+// expireRetries fails the steps still waiting for a retry once the execution deadline has
+// passed, since those retries can never run. Their OnError policies apply as for any other
+// failure; the first error that is not tolerated is returned.
+func (r *executionRun) expireRetries(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, step := range r.workflow.Steps {
+		stepState, ok := r.execution.StepStates[step.ID]
+		if !ok || stepState.Status != StepStatusRetrying {
+			continue
+		}
+		if err := r.recordFailure(ctx, step, stepState, ErrDeadlineExceeded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEngine_Execute_StepTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			// A hung tool that ignores its context must still be cut off.
+			<-release
+			return nil, nil
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{})
+
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "hang", Tool: "sire:local/test.hang", Timeout: "20ms", Retry: &RetryPolicy{MaxAttempts: 2}},
+		},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	start := time.Now()
+	_, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if !errors.Is(err, ErrStepTimeout) {
+		t.Fatalf("expected ErrStepTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the step to be cut off after its timeout, took %s", elapsed)
+	}
+
+	state := execution.StepStates["hang"]
+	if state.Status != StepStatusRetrying {
+		t.Errorf("expected a timed-out step with attempts left to be retrying, got %s", state.Status)
+	}
+	if state.ErrorCode != ErrorCodeTimeout {
+		t.Errorf("expected error code %q, got %q", ErrorCodeTimeout, state.ErrorCode)
+	}
+}
+
+func TestEngine_Execute_WorkflowDeadline(t *testing.T) {
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			if tool == "sire:local/test.fast" {
+				return map[string]interface{}{}, nil
+			}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{})
+
+	workflow := &Workflow{
+		ID:      "test-workflow",
+		Timeout: "30ms",
+		Steps: []Step{
+			{ID: "fast", Tool: "sire:local/test.fast"},
+			{ID: "slow", Tool: "sire:local/test.slow", Timeout: "1m", Retry: &RetryPolicy{MaxAttempts: 3}},
+		},
+		Edges: []Edge{{From: "fast", To: "slow"}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	_, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if !errors.Is(err, ErrDeadlineExceeded) {
+		t.Fatalf("expected ErrDeadlineExceeded, got %v", err)
+	}
+	if execution.Deadline.IsZero() {
+		t.Errorf("expected the execution deadline to be recorded")
+	}
+	if execution.Status != ExecutionStatusFailed {
+		t.Errorf("expected execution status failed, got %s", execution.Status)
+	}
+
+	state := execution.StepStates["slow"]
+	if state.Status != StepStatusFailed {
+		t.Errorf("expected no retry past the execution deadline, got %s", state.Status)
+	}
+	if state.ErrorCode != ErrorCodeDeadlineExceeded {
+		t.Errorf("expected error code %q, got %q", ErrorCodeDeadlineExceeded, state.ErrorCode)
+	}
+	if execution.StepStates["fast"].Status != StepStatusCompleted {
+		t.Errorf("expected fast step to complete before the deadline")
+	}
+}
+
+func TestEngine_Execute_DeadlineWhileWaitingForRetry(t *testing.T) {
+	for _, tc := range []struct {
+		mode     RetryMode
+		deadline time.Duration // From now
+	}{
+		// Wait mode sleeps until the deadline; handoff mode is resumed by the agent after it.
+		{RetryModeWait, 50 * time.Millisecond},
+		{RetryModeHandoff, -time.Millisecond},
+	} {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			var compensated bool
+			dispatcher := &MockDispatcher{
+				DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+					if tool == "sire:local/test.undo" {
+						compensated = true
+					}
+					return map[string]interface{}{}, nil
+				},
+			}
+			engine := NewEngine(dispatcher, &MockStore{}, WithRetryMode(tc.mode))
+
+			workflow := &Workflow{
+				ID:      "test-workflow",
+				Timeout: "1h",
+				Steps: []Step{
+					{ID: "setup", Tool: "sire:local/test.setup", Compensate: &ToolCall{Tool: "sire:local/test.undo"}},
+					{ID: "flaky", Tool: "sire:local/test.flaky", Retry: &RetryPolicy{MaxAttempts: 3}},
+				},
+				Edges: []Edge{{From: "setup", To: "flaky"}},
+			}
+			// A resumed execution whose retry is due after its deadline, e.g. because the
+			// deadline was recorded by an earlier run.
+			execution := &Execution{
+				ID:              "test-execution",
+				WorkflowID:      workflow.ID,
+				Status:          ExecutionStatusRunning,
+				Deadline:        time.Now().Add(tc.deadline),
+				CompletionOrder: []string{"setup"},
+				StepStates: map[string]*StepState{
+					"setup": {Status: StepStatusCompleted, Attempts: 1},
+					"flaky": {Status: StepStatusRetrying, Attempts: 1, Error: "boom", NextAttempt: time.Now().Add(time.Hour)},
+				},
+			}
+
+			start := time.Now()
+			_, err := engine.Execute(context.Background(), execution, workflow, nil)
+			if !errors.Is(err, ErrDeadlineExceeded) {
+				t.Fatalf("expected ErrDeadlineExceeded, got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("expected the wait to end at the deadline, took %s", elapsed)
+			}
+			if execution.Status != ExecutionStatusFailed {
+				t.Errorf("expected execution status failed, got %s", execution.Status)
+			}
+			if state := execution.StepStates["flaky"]; state.Status != StepStatusFailed || state.ErrorCode != ErrorCodeDeadlineExceeded {
+				t.Errorf("expected the retrying step to fail with %q, got %+v", ErrorCodeDeadlineExceeded, state)
+			}
+			if !compensated {
+				t.Errorf("expected the completed step to be compensated")
+			}
+		})
+	}
+}
+
+func TestEngine_Execute_InvalidTimeout(t *testing.T) {
+	engine := NewEngine(&MockDispatcher{}, &MockStore{})
+	workflow := &Workflow{
+		ID:    "test-workflow",
+		Steps: []Step{{ID: "step1", Tool: "sire:local/test.step", Timeout: "soon"}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	_, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid timeout") {
+		t.Fatalf("expected an invalid timeout error, got %v", err)
+	}
+	if _, ok := execution.StepStates["step1"]; ok {
+		t.Errorf("expected no step to run with an invalid timeout")
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// isTimer reports whether the step is a sleep or wait_until step.
+func (s Step) isTimer() bool {
+	return s.Sleep != "" || s.WaitUntil != ""
+}
+
+// runTimer runs a sleep or wait_until step. Its wake-up time is recorded in stepState.WakeAt
+// when the step starts, so the execution can be parked while it waits and resumed by the
+// agent once the timer fires. The step output is {"woke_at": <RFC 3339 wake-up time>}.
+func (r *executionRun) runTimer(ctx context.Context, step Step, stepState *StepState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if stepState.Status != StepStatusWaiting {
+		wakeAt, err := r.wakeTime(step, now)
+		if err != nil {
+			r.failStep(stepState, err)
+			return fmt.Errorf("error resolving wake-up time for step %s: %w", step.ID, err)
+		}
+		stepState.Status = StepStatusWaiting
+		stepState.WakeAt = wakeAt
+	}
+
+	switch {
+	case !now.Before(stepState.WakeAt):
+		wakeAt := stepState.WakeAt
+		stepState.Attempts++
+		return r.completeStep(step, stepState, map[string]interface{}{"woke_at": wakeAt.Format(time.RFC3339)})
+	case ctx.Err() != nil:
+		stepState.Attempts++
+		return r.recordFailure(ctx, step, stepState, ErrDeadlineExceeded)
+	}
+	if err := r.save(); err != nil {
+		return fmt.Errorf("failed to save execution state after step %s: %w", step.ID, err)
+	}
+	return nil
+}
+
+// wakeTime computes when a timer step started at now fires. The caller must hold r.mu.
+func (r *executionRun) wakeTime(step Step, now time.Time) (time.Time, error) {
+	if step.Sleep != "" {
+		d, err := time.ParseDuration(step.Sleep)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid sleep %q: %w", step.Sleep, err)
+		}
+		return now.Add(d), nil
+	}
+	value, err := resolveString(step.WaitUntil, r.stepData(step))
+	if err != nil {
+		return time.Time{}, err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("wait_until must be an RFC 3339 timestamp, got %T", value)
+	}
+	wakeAt, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid wait_until %q: %w", s, err)
+	}
+	return wakeAt, nil
+}
+
+// validateTimers checks that timer steps set exactly one of sleep and wait_until, do not
+// also dispatch a tool, and use a valid duration or timestamp.
+func validateTimers(workflow *Workflow) error {
+	var errs []error
+	for _, step := range workflow.Steps {
+		if !step.isTimer() {
+			continue
+		}
+		if step.Sleep != "" && step.WaitUntil != "" {
+			errs = append(errs, fmt.Errorf("step %s: sleep and wait_until are mutually exclusive", step.ID))
+		}
+		if step.Tool != "" || step.Foreach != "" || step.Workflow != "" || step.Signal != nil || step.Fallback != nil {
+			errs = append(errs, fmt.Errorf("step %s: timer steps cannot set tool, foreach, workflow, signal or fallback", step.ID))
+		}
+		if step.Sleep != "" {
+			if d, err := time.ParseDuration(step.Sleep); err != nil || d < 0 {
+				errs = append(errs, fmt.Errorf("step %s: invalid sleep %q (expected a non-negative Go duration such as \"24h\")", step.ID, step.Sleep))
+			}
+		}
+		// Templated timestamps are checked when the step starts.
+		if step.WaitUntil != "" && !strings.Contains(step.WaitUntil, "{{") {
+			if _, err := time.Parse(time.RFC3339, step.WaitUntil); err != nil {
+				errs = append(errs, fmt.Errorf("step %s: invalid wait_until %q (expected an RFC 3339 timestamp)", step.ID, step.WaitUntil))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
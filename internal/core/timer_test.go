@@ -0,0 +1,143 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEngine_Execute_SleepStep_Handoff(t *testing.T) {
+	store := &MockStore{}
+	engine := NewEngine(&MockDispatcher{}, store)
+
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "wait", Sleep: "30ms"},
+			{ID: "remind", Tool: "sire:local/test.remind"},
+		},
+		Edges: []Edge{{From: "wait", To: "remind"}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, Workflow: workflow, StepStates: make(map[string]*StepState)}
+
+	start := time.Now()
+	if _, err := engine.Execute(context.Background(), execution, workflow, nil); !errors.Is(err, ErrExecutionWaiting) {
+		t.Fatalf("expected ErrExecutionWaiting, got %v", err)
+	}
+	if execution.Status != ExecutionStatusWaiting {
+		t.Errorf("expected status %q, got %q", ExecutionStatusWaiting, execution.Status)
+	}
+	wait := execution.StepStates["wait"]
+	wakeAt := wait.WakeAt
+	if wait.Status != StepStatusWaiting || wakeAt.Before(start.Add(30*time.Millisecond)) {
+		t.Fatalf("expected the sleep to be recorded as a wake-up time, got %+v", wait)
+	}
+	if Woken(store, execution, time.Now()) {
+		t.Errorf("expected the execution to stay parked before the timer fires")
+	}
+	if !Woken(store, execution, wakeAt) {
+		t.Errorf("expected the execution to be woken once the timer fires")
+	}
+
+	// Resuming early leaves the timer as it is.
+	if _, err := engine.Execute(context.Background(), execution, workflow, nil); !errors.Is(err, ErrExecutionWaiting) {
+		t.Fatalf("expected ErrExecutionWaiting, got %v", err)
+	}
+	if !execution.StepStates["wait"].WakeAt.Equal(wakeAt) {
+		t.Errorf("expected the wake-up time to be kept across resumes")
+	}
+
+	time.Sleep(time.Until(wakeAt))
+	if _, err := engine.Execute(context.Background(), execution, workflow, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if execution.Status != ExecutionStatusCompleted {
+		t.Errorf("expected status %q, got %q", ExecutionStatusCompleted, execution.Status)
+	}
+	if got := execution.StepStates["wait"].Output["woke_at"]; got != wakeAt.Format(time.RFC3339) {
+		t.Errorf("expected woke_at %q, got %v", wakeAt.Format(time.RFC3339), got)
+	}
+	if execution.StepStates["remind"].Status != StepStatusCompleted {
+		t.Errorf("expected the step after the timer to complete, got %+v", execution.StepStates["remind"])
+	}
+}
+
+func TestEngine_Execute_SleepStep_Wait(t *testing.T) {
+	engine := NewEngine(&MockDispatcher{}, &MockStore{}, WithRetryMode(RetryModeWait))
+
+	workflow := &Workflow{
+		ID:    "test-workflow",
+		Steps: []Step{{ID: "wait", Sleep: "1h"}},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	// Timers park the execution in wait mode too, rather than holding the process.
+	start := time.Now()
+	if _, err := engine.Execute(context.Background(), execution, workflow, nil); !errors.Is(err, ErrExecutionWaiting) {
+		t.Fatalf("expected ErrExecutionWaiting, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Execute to return without sleeping, took %s", elapsed)
+	}
+	if execution.Status != ExecutionStatusWaiting {
+		t.Errorf("expected status %q, got %q", ExecutionStatusWaiting, execution.Status)
+	}
+}
+
+func TestEngine_Execute_WaitUntilStep(t *testing.T) {
+	engine := NewEngine(&MockDispatcher{}, &MockStore{})
+
+	workflow := &Workflow{
+		ID:    "test-workflow",
+		Steps: []Step{{ID: "wait", WaitUntil: "{{ .inputs.remind_at }}"}},
+	}
+
+	// A timestamp in the past fires immediately.
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+	if _, err := engine.Execute(context.Background(), execution, workflow, map[string]interface{}{"remind_at": past}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := execution.StepStates["wait"].Output["woke_at"]; got != past {
+		t.Errorf("expected woke_at %q, got %v", past, got)
+	}
+
+	execution = &Execution{ID: "test-execution-2", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+	_, err := engine.Execute(context.Background(), execution, workflow, map[string]interface{}{"remind_at": "tomorrow"})
+	if err == nil || !strings.Contains(err.Error(), "invalid wait_until") {
+		t.Fatalf("expected an invalid wait_until error, got %v", err)
+	}
+	if execution.Status != ExecutionStatusFailed {
+		t.Errorf("expected status %q, got %q", ExecutionStatusFailed, execution.Status)
+	}
+}
+
+func TestValidateTimers(t *testing.T) {
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "ok", Sleep: "24h"},
+			{ID: "templated", WaitUntil: "{{ .inputs.at }}"},
+			{ID: "both", Sleep: "1h", WaitUntil: "2030-01-01T00:00:00Z"},
+			{ID: "tool", Tool: "sire:local/test.remind", Sleep: "1h"},
+			{ID: "duration", Sleep: "a day"},
+			{ID: "timestamp", WaitUntil: "2030-01-01"},
+		},
+	}
+	err := validateTimers(workflow)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	for _, stepID := range []string{"step both:", "step tool:", "step duration:", "step timestamp:"} {
+		if !strings.Contains(err.Error(), stepID) {
+			t.Errorf("expected error %q to mention %q", err, stepID)
+		}
+	}
+	for _, stepID := range []string{"step ok:", "step templated:"} {
+		if strings.Contains(err.Error(), stepID) {
+			t.Errorf("expected no error for %q, got %q", stepID, err)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+)
+
+// workflowTemplateFields are the fields of .workflow in the template data; see buildTemplateData.
+var workflowTemplateFields = []string{"id", "name", "execution_id", "started_at"}
+
+// ReferenceError is a problem with a template or expression found by ValidateReferences.
+type ReferenceError struct {
+	Owner  string // What holds the reference, e.g. "step report", "hook notify" or `output "total"`
+	StepID string // ID of the step or hook holding the reference, empty for workflow outputs
+	Output string // Name of the workflow output holding the reference, if any
+	Err    error
+}
+
+func (e *ReferenceError) Error() string {
+	return e.Owner + ": " + e.Err.Error()
+}
+
+func (e *ReferenceError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateReferences checks that every reference to step data, such as
+// {{ .steps.fetch.output.records }} in params or steps.fetch.output in a `when` or
+// `foreach` expression, names a step that is guaranteed to have run: an ancestor of the
+// referencing step in the graph. A step's compensate and fallback may also reference the
+// step itself, hooks may reference any step or hook, and workflow outputs any step. A
+// reference to an expanded matrix step stands for all of its instances.
+// Templates must also parse and only use known fields of .workflow. Every problem is
+// reported as a *ReferenceError.
+func ValidateReferences(workflow *Workflow) error {
+	known := make(map[string]bool, len(workflow.Steps))
+	for _, step := range workflow.Steps {
+		known[step.ID] = true
+	}
+	hooks := make(map[string]bool)
+	for _, hook := range workflow.hooks() {
+		hooks[hook.ID] = true
+	}
+	groups := matrixGroups(workflow)
+	for id := range groups {
+		known[id] = true
+	}
+	isStep := func(id string) bool { return known[id] || hooks[id] }
+
+	// allowedRef reports whether allowed accepts ref or, for a matrix step, all of its instances.
+	allowedRef := func(ref string, allowed func(string) bool) bool {
+		instances, ok := groups[ref]
+		if !ok {
+			return allowed(ref)
+		}
+		for _, id := range instances {
+			if !allowed(id) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var errs []error
+	check := func(owner ReferenceError, allowed func(string) bool, refs []string, err error) {
+		report := func(err error) {
+			refErr := owner
+			refErr.Err = err
+			errs = append(errs, &refErr)
+		}
+		for _, err := range splitErrors(err) {
+			report(err)
+		}
+		for _, ref := range refs {
+			switch {
+			case !known[ref] && !hooks[ref]:
+				report(fmt.Errorf("references unknown step %q", ref))
+			case !allowedRef(ref, allowed):
+				report(fmt.Errorf("references step %q, which is not an ancestor", ref))
+			}
+		}
+	}
+
+	for _, step := range workflow.Steps {
+		ancestors := ancestorsOf(workflow, step.ID)
+		isAncestor := func(ref string) bool { return ancestors[ref] }
+		isAncestorOrSelf := func(ref string) bool { return ancestors[ref] || ref == step.ID }
+		owner := ReferenceError{Owner: "step " + step.ID, StepID: step.ID}
+
+		refs, err := stepReferences(step, isStep)
+		check(owner, isAncestor, refs, err)
+		var matrixRoots []string
+		if step.MatrixValues != nil {
+			matrixRoots = []string{"matrix"}
+		}
+		if step.Compensate != nil {
+			refs, err := paramReferences(step.Compensate.Params, matrixRoots...)
+			check(ReferenceError{Owner: owner.Owner + " compensate", StepID: step.ID}, isAncestorOrSelf, refs, err)
+		}
+		if step.Fallback != nil {
+			refs, err := paramReferences(step.Fallback.Params, matrixRoots...)
+			check(ReferenceError{Owner: owner.Owner + " fallback", StepID: step.ID}, isAncestorOrSelf, refs, err)
+		}
+	}
+
+	anyStep := func(string) bool { return true }
+	for _, hook := range workflow.hooks() {
+		refs, err := stepReferences(hook, isStep)
+		check(ReferenceError{Owner: "hook " + hook.ID, StepID: hook.ID}, anyStep, refs, err)
+	}
+	for _, output := range workflow.Outputs {
+		refs, err := exprReferences(output.Value, isStep)
+		check(ReferenceError{Owner: fmt.Sprintf("output %q", output.Name), Output: output.Name}, func(ref string) bool { return known[ref] }, refs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// splitErrors returns the errors joined in err, or err itself if it is not a join.
+func splitErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+// validateWiring checks the workflow's wiring mode. Strict workflows must also only
+// reference ancestor steps, since that is the only way their steps receive upstream data.
+func validateWiring(workflow *Workflow) error {
+	switch workflow.Wiring {
+	case "", WiringMerge:
+		return nil
+	case WiringStrict:
+		return ValidateReferences(workflow)
+	default:
+		return fmt.Errorf("workflow %s: unknown wiring %q (expected %s or %s)", workflow.ID, workflow.Wiring, WiringMerge, WiringStrict)
+	}
+}
+
+// ancestorsOf returns the IDs of every step that stepID depends on, directly or transitively.
+func ancestorsOf(workflow *Workflow, stepID string) map[string]bool {
+	ancestors := make(map[string]bool)
+	queue := []string{stepID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, dep := range dependenciesOf(workflow, id) {
+			if !ancestors[dep] {
+				ancestors[dep] = true
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return ancestors
+}
+
+// stepReferences returns the steps referenced by a step's params, `when`, `foreach` and
+// `wait_until`, sorted and without duplicates. isStep reports which bare identifiers in
+// expressions name a step; see exprReferences.
+func stepReferences(step Step, isStep func(string) bool) ([]string, error) {
+	var refs []string
+	var errs []error
+	add := func(found []string, err error) {
+		refs = append(refs, found...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	var matrixRoots []string
+	if step.MatrixValues != nil {
+		matrixRoots = []string{"matrix"}
+	}
+	itemRoots := matrixRoots
+	if step.Foreach != "" {
+		itemRoots = append([]string{"item", "index"}, matrixRoots...)
+	}
+	add(paramReferences(step.Params, itemRoots...))
+	add(exprReferences(step.When, isStep))
+	add(exprReferences(step.Foreach, isStep))
+	add(templateReferences(step.WaitUntil, matrixRoots...))
+	return uniqueSorted(refs), errors.Join(errs...)
+}
+
+// paramReferences returns the steps referenced by the templates in params, which may be
+// nested in maps and lists. Templates may also use the given roots besides templateRoots.
+func paramReferences(params map[string]interface{}, extraRoots ...string) ([]string, error) {
+	var refs []string
+	var errs []error
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case string:
+			found, err := templateReferences(v, extraRoots...)
+			refs = append(refs, found...)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case map[string]interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(params)
+	return uniqueSorted(refs), errors.Join(errs...)
+}
+
+// templateReferences returns the steps referenced as .steps.<id>, $.steps.<id> or the
+// .<id> shorthand in a template. Any top-level field other than the reserved names and
+// extraRoots is taken as the shorthand. Unknown fields of .workflow are reported as errors.
+// Within range and with blocks, where the dot is rebound, only $-rooted fields are checked.
+func templateReferences(s string, extraRoots ...string) ([]string, error) {
+	if !strings.Contains(s, "{{") {
+		return nil, nil
+	}
+	tree := parse.New("param")
+	tree.Mode = parse.SkipFuncCheck
+	if _, err := tree.Parse(s, "", "", make(map[string]*parse.Tree)); err != nil {
+		return nil, fmt.Errorf("invalid template %q: %w", s, err)
+	}
+
+	var refs, unknown []string
+	visitFields := func(ident []string) {
+		switch {
+		case ident[0] == "steps":
+			if len(ident) >= 2 {
+				refs = append(refs, ident[1])
+			}
+		case ident[0] == "workflow":
+			if len(ident) >= 2 && !slices.Contains(workflowTemplateFields, ident[1]) {
+				unknown = append(unknown, ident[1])
+			}
+		case !reservedTemplateKeys[ident[0]] && !slices.Contains(extraRoots, ident[0]):
+			refs = append(refs, ident[0])
+		}
+	}
+
+	// rebound is true where the dot no longer refers to the template data.
+	var walk func(node parse.Node, rebound bool)
+	walk = func(node parse.Node, rebound bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child, rebound)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe, rebound)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd, rebound)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg, rebound)
+			}
+		case *parse.ChainNode:
+			walk(n.Node, rebound)
+		case *parse.IfNode:
+			walk(n.Pipe, rebound)
+			walk(n.List, rebound)
+			walk(n.ElseList, rebound)
+		case *parse.RangeNode:
+			walk(n.Pipe, rebound)
+			walk(n.List, true)
+			walk(n.ElseList, rebound)
+		case *parse.WithNode:
+			walk(n.Pipe, rebound)
+			walk(n.List, true)
+			walk(n.ElseList, rebound)
+		case *parse.TemplateNode:
+			walk(n.Pipe, rebound)
+		case *parse.FieldNode:
+			if !rebound {
+				visitFields(n.Ident)
+			}
+		case *parse.VariableNode:
+			if len(n.Ident) >= 2 && n.Ident[0] == "$" {
+				visitFields(n.Ident[1:])
+			}
+		}
+	}
+	walk(tree.Root, false)
+
+	var errs []error
+	for _, field := range uniqueSorted(unknown) {
+		errs = append(errs, fmt.Errorf("template %q references unknown field .workflow.%s (expected one of .workflow.%s)", s, field, strings.Join(workflowTemplateFields, ", .workflow.")))
+	}
+	return refs, errors.Join(errs...)
+}
+
+// exprReferences returns the steps referenced as steps.<id>, steps["<id>"] or the bare <id>
+// shorthand in an expr-lang expression. Since expressions may also use builtins and their
+// own variables, a bare identifier is only taken as a reference if isStep accepts it.
+func exprReferences(expression string, isStep func(string) bool) ([]string, error) {
+	if expression == "" {
+		return nil, nil
+	}
+	tree, err := parser.Parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", expression, err)
+	}
+	v := &referenceVisitor{isStep: isStep}
+	ast.Walk(&tree.Node, v)
+	return v.refs, nil
+}
+
+// referenceVisitor collects the step IDs of steps.<id> member accesses and of identifiers
+// that name a step.
+type referenceVisitor struct {
+	isStep func(string) bool
+	refs   []string
+}
+
+func (v *referenceVisitor) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		if !reservedTemplateKeys[n.Value] && v.isStep(n.Value) {
+			v.refs = append(v.refs, n.Value)
+		}
+	case *ast.MemberNode:
+		ident, ok := n.Node.(*ast.IdentifierNode)
+		if !ok || ident.Value != "steps" {
+			return
+		}
+		if property, ok := n.Property.(*ast.StringNode); ok {
+			v.refs = append(v.refs, property.Value)
+		}
+	}
+}
+
+// uniqueSorted returns the distinct values of s in sorted order.
+func uniqueSorted(s []string) []string {
+	seen := make(map[string]bool, len(s))
+	var unique []string
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEngine_Execute_StrictWiring(t *testing.T) {
+	received := make(map[string]map[string]interface{})
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			switch tool {
+			case "sire:local/test.users":
+				return map[string]interface{}{"result": []interface{}{"alice"}}, nil
+			case "sire:local/test.orders":
+				return map[string]interface{}{"result": []interface{}{"order-1"}}, nil
+			}
+			received[tool] = params
+			return map[string]interface{}{}, nil
+		},
+	}
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "users", Tool: "sire:local/test.users"},
+			{ID: "orders", Tool: "sire:local/test.orders"},
+			{ID: "report", Tool: "sire:local/test.report", Params: map[string]interface{}{
+				"users":  "{{ .steps.users.output.result }}",
+				"orders": "{{ .steps.orders.output.result }}",
+			}},
+		},
+		Edges: []Edge{{From: "users", To: "report"}, {From: "orders", To: "report"}},
+	}
+
+	// By default the parents' outputs are merged, so one `result` overwrites the other.
+	engine := NewEngine(dispatcher, &MockStore{})
+	execution := &Execution{ID: "merge-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+	if _, err := engine.Execute(context.Background(), execution, workflow, map[string]interface{}{"region": "eu"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := received["sire:local/test.report"]["result"]; !ok {
+		t.Errorf("expected merged parent outputs, got %v", received["sire:local/test.report"])
+	}
+
+	workflow.Wiring = WiringStrict
+	execution = &Execution{ID: "strict-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+	if _, err := engine.Execute(context.Background(), execution, workflow, map[string]interface{}{"region": "eu"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	params := received["sire:local/test.report"]
+	if len(params) != 2 {
+		t.Errorf("expected only the step's own params, got %v", params)
+	}
+	if users, ok := params["users"].([]interface{}); !ok || users[0] != "alice" {
+		t.Errorf("expected users from the users step, got %v", params["users"])
+	}
+	if orders, ok := params["orders"].([]interface{}); !ok || orders[0] != "order-1" {
+		t.Errorf("expected orders from the orders step, got %v", params["orders"])
+	}
+}
+
+func TestEngine_Execute_StrictWiring_RejectsNonAncestorReferences(t *testing.T) {
+	engine := NewEngine(&MockDispatcher{}, &MockStore{})
+	workflow := &Workflow{
+		ID:     "test-workflow",
+		Wiring: WiringStrict,
+		Steps: []Step{
+			{ID: "a", Tool: "sire:local/test.a"},
+			{ID: "b", Tool: "sire:local/test.b", Params: map[string]interface{}{"value": "{{ .steps.a.output.value }}"}},
+		},
+	}
+	execution := &Execution{ID: "test-execution", WorkflowID: workflow.ID, StepStates: make(map[string]*StepState)}
+
+	_, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil || !strings.Contains(err.Error(), `step b: references step "a", which is not an ancestor`) {
+		t.Fatalf("expected a non-ancestor reference error, got %v", err)
+	}
+	if execution.Status != ExecutionStatusFailed {
+		t.Errorf("expected status %q, got %q", ExecutionStatusFailed, execution.Status)
+	}
+}
+
+func TestValidateReferences(t *testing.T) {
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "fetch", Tool: "sire:local/test.fetch"},
+			{ID: "parse", Tool: "sire:local/test.parse", Params: map[string]interface{}{
+				"records": "{{ .steps.fetch.output.records }}",
+			}},
+			{ID: "store", Tool: "sire:local/test.store",
+				When:    "steps.fetch.output.count > 0",
+				Foreach: `steps["parse"].output.items`,
+				Params: map[string]interface{}{
+					"nested": []interface{}{map[string]interface{}{"id": "{{ $.steps.parse.output.id }}"}},
+				},
+				Compensate: &ToolCall{Tool: "sire:local/test.unstore", Params: map[string]interface{}{"id": "{{ .steps.store.output.id }}"}},
+			},
+			{ID: "audit", Tool: "sire:local/test.audit", Params: map[string]interface{}{
+				"parsed": "{{ if eq .steps.parse.status \"completed\" }}yes{{ end }}",
+				"typo":   "{{ .steps.fetc.output }}",
+			}, When: "steps.store.status == 'completed'"},
+		},
+		Edges: []Edge{{From: "fetch", To: "parse"}, {From: "parse", To: "store"}, {From: "fetch", To: "audit"}},
+		Outputs: []Output{
+			{Name: "stored", Value: "steps.store.output"},
+			{Name: "missing", Value: "steps.nope.output"},
+		},
+		Finally: []Step{{ID: "notify", Tool: "sire:local/test.notify", Params: map[string]interface{}{"stored": "{{ .steps.store.status }}"}}},
+	}
+
+	err := ValidateReferences(workflow)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	for _, want := range []string{
+		`step audit: references step "parse", which is not an ancestor`,
+		`step audit: references step "store", which is not an ancestor`,
+		`step audit: references unknown step "fetc"`,
+		`output "missing": references unknown step "nope"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error %q to contain %q", err, want)
+		}
+	}
+	for _, unwanted := range []string{"step parse:", "step store", "hook notify", `output "stored"`} {
+		if strings.Contains(err.Error(), unwanted) {
+			t.Errorf("expected no error for %q, got %q", unwanted, err)
+		}
+	}
+
+	workflow.Steps = workflow.Steps[:3]
+	workflow.Outputs = workflow.Outputs[:1]
+	if err := ValidateReferences(workflow); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateReferences_InvalidTemplate(t *testing.T) {
+	workflow := &Workflow{
+		ID:    "test-workflow",
+		Steps: []Step{{ID: "a", Tool: "sire:local/test.a", Params: map[string]interface{}{"value": "{{ .steps.a "}}},
+	}
+	if err := ValidateReferences(workflow); err == nil || !strings.Contains(err.Error(), "step a: invalid template") {
+		t.Errorf("expected an invalid template error, got %v", err)
+	}
+}
+
+func TestValidateReferences_ShorthandAndWorkflowFields(t *testing.T) {
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "a", Tool: "sire:local/test.a"},
+			{ID: "b", Tool: "sire:local/test.b", Foreach: "steps.a.output.items", Params: map[string]interface{}{
+				"value": "{{ .a.output.value }} {{ .item }} {{ .workflow.id }}",
+				"names": "{{ range .inputs.users }}{{ .name }}{{ end }}",
+			}},
+			{ID: "c", Tool: "sire:local/test.c", Params: map[string]interface{}{
+				"value":  "{{ .b.output }}",
+				"secret": "{{ .workflow.secrets.token }}",
+			}},
+		},
+		Edges: []Edge{{From: "a", To: "b"}},
+	}
+
+	err := ValidateReferences(workflow)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	for _, want := range []string{
+		`step c: references step "b", which is not an ancestor`,
+		`step c: template "{{ .workflow.secrets.token }}" references unknown field .workflow.secrets`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error %q to contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "step b") {
+		t.Errorf("expected no error for step b, got %q", err)
+	}
+}
+
+func TestValidateReferences_ExpressionShorthand(t *testing.T) {
+	workflow := &Workflow{
+		ID: "test-workflow",
+		Steps: []Step{
+			{ID: "fetch", Tool: "sire:local/test.fetch"},
+			{ID: "store", Tool: "sire:local/test.store",
+				When:    `fetch.status == "completed" && len(inputs.users) > 0`,
+				Foreach: "filter(fetch.output.records, {.id != nil})",
+			},
+			{ID: "audit", Tool: "sire:local/test.audit", When: "let n = len(store.output); n > 0"},
+		},
+		Edges:   []Edge{{From: "store", To: "audit"}},
+		Outputs: []Output{{Name: "count", Value: "len(audit.output) + len(nope)"}},
+	}
+
+	err := ValidateReferences(workflow)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if want := `step store: references step "fetch", which is not an ancestor`; !strings.Contains(err.Error(), want) {
+		t.Errorf("expected error %q to contain %q", err, want)
+	}
+	// Bare identifiers that name no step, such as builtins, inputs and variables, are not references.
+	for _, unwanted := range []string{"step audit", `output "count"`, `"len"`, `"inputs"`, `"n"`} {
+		if strings.Contains(err.Error(), unwanted) {
+			t.Errorf("expected no error for %q, got %q", unwanted, err)
+		}
+	}
+
+	workflow.Edges = append(workflow.Edges, Edge{From: "fetch", To: "store"})
+	if err := ValidateReferences(workflow); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
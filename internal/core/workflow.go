@@ -4,30 +4,166 @@ import "time" // Import time package for time.Time
 
 // Step represents a single unit of work in a workflow.
 type Step struct {
-	ID     string                 `yaml:"id"`
-	Tool   string                 `yaml:"tool"`
-	Params map[string]interface{} `yaml:"params,omitempty"`
-	Retry  *RetryPolicy           `yaml:"retry,omitempty"`
+	ID     string                 `yaml:"id" json:"id"`
+	Tool   string                 `yaml:"tool" json:"tool"`
+	Params map[string]interface{} `yaml:"params,omitempty" json:"params,omitempty"`
+	Retry  *RetryPolicy           `yaml:"retry,omitempty" json:"retry,omitempty"`
+	// DependsOn lists the steps that must settle before this one runs, as an alternative
+	// to declaring edges at the workflow level.
+	DependsOn []string `yaml:"depends_on,omitempty" json:"depends_on,omitempty"` //nolint:tagliatelle
+	// When is an optional expr-lang condition; the step is skipped when it evaluates to false.
+	When       string     `yaml:"when,omitempty" json:"when,omitempty"`
+	SkipPolicy SkipPolicy `yaml:"skip_policy,omitempty" json:"skip_policy,omitempty"` //nolint:tagliatelle
+	// Foreach is an optional expr-lang expression yielding a list; the tool is dispatched once per item,
+	// which params can reference as {{ .item }} and {{ .index }}.
+	Foreach     string `yaml:"foreach,omitempty" json:"foreach,omitempty"`
+	Concurrency int    `yaml:"concurrency,omitempty" json:"concurrency,omitempty"` // Max foreach items in flight, 0 means unlimited
+	// Workflow runs another workflow, referenced by registered ID or file path, as a child execution
+	// instead of dispatching a tool. Params become the child's inputs; nothing else is passed.
+	Workflow string `yaml:"workflow,omitempty" json:"workflow,omitempty"`
+	// Compensate undoes the step's work if the execution fails after the step completed.
+	// Its params can refer to the step's own output via .steps.<id>.output.
+	Compensate *ToolCall `yaml:"compensate,omitempty" json:"compensate,omitempty"`
+	// OnError decides what a step failure does to the execution once retries are exhausted.
+	OnError OnErrorPolicy `yaml:"on_error,omitempty" json:"on_error,omitempty"` //nolint:tagliatelle
+	// Fallback is dispatched when the step fails and OnError is "fallback"; its output
+	// replaces the step's. Its params can read the failure via .steps.<id>.error.
+	Fallback *ToolCall `yaml:"fallback,omitempty" json:"fallback,omitempty"`
+	// Signal makes the step wait for an external signal instead of dispatching a tool;
+	// the signal's payload becomes the step output.
+	Signal *SignalSpec `yaml:"signal,omitempty" json:"signal,omitempty"`
+	// Sleep pauses the execution for a Go duration such as "24h" instead of dispatching a tool.
+	Sleep string `yaml:"sleep,omitempty" json:"sleep,omitempty"`
+	// WaitUntil pauses the execution until an RFC 3339 timestamp, which may be a template.
+	WaitUntil string `yaml:"wait_until,omitempty" json:"wait_until,omitempty"` //nolint:tagliatelle
+	// Timeout bounds a single attempt of the step, as a Go duration such as "30s".
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// Matrix runs the step once per combination of its axis values; see Workflow.ExpandMatrix.
+	Matrix Matrix `yaml:"matrix,omitempty" json:"matrix,omitempty"`
+	// MatrixOf and MatrixValues are set on the instances of an expanded matrix step: the
+	// matrix step's ID, and the instance's combination, which templates read as .matrix.<axis>.
+	MatrixOf     string                 `yaml:"-" json:"matrix_of,omitempty"`     //nolint:tagliatelle
+	MatrixValues map[string]interface{} `yaml:"-" json:"matrix_values,omitempty"` //nolint:tagliatelle
+}
+
+// SignalSpec configures a step that waits for an external signal, e.g. a human approval.
+type SignalSpec struct {
+	// Timeout is how long to wait, as a Go duration; the step then fails with the timeout
+	// error code. Empty means wait indefinitely.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// SkipPolicy defines how a step reacts to a skipped dependency.
+type SkipPolicy string
+
+const (
+	// SkipPolicyPropagate skips the step when any of its dependencies was skipped. This is the default.
+	SkipPolicyPropagate SkipPolicy = "propagate"
+	// SkipPolicyIgnore treats skipped dependencies as satisfied, e.g. for a step joining two exclusive branches.
+	SkipPolicyIgnore SkipPolicy = "ignore"
+)
+
+// OnErrorPolicy defines how a step failure affects the execution.
+type OnErrorPolicy string
+
+const (
+	// OnErrorFail fails the execution. It is the default.
+	OnErrorFail OnErrorPolicy = "fail"
+	// OnErrorContinue keeps the step failed but lets its dependants and the execution go on.
+	OnErrorContinue OnErrorPolicy = "continue"
+	// OnErrorFallback dispatches the step's Fallback tool and uses its output instead.
+	OnErrorFallback OnErrorPolicy = "fallback"
+)
+
+// WiringMode defines which data a step receives when it is dispatched.
+type WiringMode string
+
+const (
+	// WiringMerge passes a step the workflow inputs, then the outputs of its parent steps,
+	// then its own params, merged into one map. It is the default.
+	WiringMerge WiringMode = "merge"
+	// WiringStrict passes a step its own params only, which reference upstream data by
+	// name, e.g. {{ .steps.fetch.output.records }}.
+	WiringStrict WiringMode = "strict"
+)
+
+// ToolCall is an additional tool invocation attached to a step, such as its compensation
+// or fallback. Its params are resolved like step params.
+type ToolCall struct {
+	Tool   string                 `yaml:"tool" json:"tool"`
+	Params map[string]interface{} `yaml:"params,omitempty" json:"params,omitempty"`
 }
 
 // RetryPolicy defines the retry behavior for a step.
+// Intervals are Go durations; see delay for how they combine.
 type RetryPolicy struct {
-	MaxAttempts int    `yaml:"max_attempts"` //nolint:tagliatelle
-	Backoff     string `yaml:"backoff"` // e.g., "exponential"
+	MaxAttempts     int     `yaml:"max_attempts" json:"max_attempts"`                             //nolint:tagliatelle
+	Backoff         string  `yaml:"backoff" json:"backoff"`                                       // "fixed" (default), "linear" or "exponential"
+	InitialInterval string  `yaml:"initial_interval,omitempty" json:"initial_interval,omitempty"` //nolint:tagliatelle // Defaults to 5s for fixed, 1s otherwise
+	Multiplier      float64 `yaml:"multiplier,omitempty" json:"multiplier,omitempty"`             // Growth factor for exponential backoff, defaults to 2
+	MaxInterval     string  `yaml:"max_interval,omitempty" json:"max_interval,omitempty"`         //nolint:tagliatelle // Cap on the computed interval
+	Jitter          float64 `yaml:"jitter,omitempty" json:"jitter,omitempty"`                     // Fraction (0-1) of the interval randomly taken off
+	// RetryableErrors restricts retries to errors whose code or message matches one of
+	// these patterns. NonRetryableErrors lists errors that are never retried.
+	RetryableErrors    []string `yaml:"retryable_errors,omitempty" json:"retryable_errors,omitempty"`         //nolint:tagliatelle
+	NonRetryableErrors []string `yaml:"non_retryable_errors,omitempty" json:"non_retryable_errors,omitempty"` //nolint:tagliatelle
 }
 
 // Workflow defines the structure of a workflow.
 type Workflow struct {
-	ID    string `yaml:"id"`
-	Name  string `yaml:"name"`
-	Steps []Step `yaml:"steps"`
-	Edges []Edge `yaml:"edges"`
+	ID      string   `yaml:"id" json:"id"`
+	Name    string   `yaml:"name" json:"name"`
+	Inputs  []Input  `yaml:"inputs,omitempty" json:"inputs,omitempty"`
+	Outputs []Output `yaml:"outputs,omitempty" json:"outputs,omitempty"`
+	// OnSuccess, OnFailure and Finally are hook steps run one after another once the steps
+	// above have finished: OnSuccess when the execution completed, OnFailure when it failed,
+	// and Finally in both cases, after the others.
+	OnSuccess []Step `yaml:"on_success,omitempty" json:"on_success,omitempty"` //nolint:tagliatelle
+	OnFailure []Step `yaml:"on_failure,omitempty" json:"on_failure,omitempty"` //nolint:tagliatelle
+	Finally   []Step `yaml:"finally,omitempty" json:"finally,omitempty"`
+	// Retry is the default retry policy for steps that do not declare their own.
+	Retry *RetryPolicy `yaml:"retry,omitempty" json:"retry,omitempty"`
+	// Timeout bounds the whole execution, as a Go duration such as "1h".
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// Wiring decides which data a step receives when it is dispatched.
+	Wiring WiringMode `yaml:"wiring,omitempty" json:"wiring,omitempty"`
+	Steps  []Step     `yaml:"steps" json:"steps"`
+	Edges  EdgeList   `yaml:"edges" json:"edges"`
+}
+
+// Input declares a workflow input. Inputs are checked and defaulted before a run starts.
+type Input struct {
+	Name        string      `yaml:"name" json:"name"`
+	Type        InputType   `yaml:"type,omitempty" json:"type,omitempty"` // Empty accepts any value
+	Required    bool        `yaml:"required,omitempty" json:"required,omitempty"`
+	Default     interface{} `yaml:"default,omitempty" json:"default,omitempty"`
+	Description string      `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+// InputType is the type of a declared workflow input.
+type InputType string
+
+const (
+	InputTypeString  InputType = "string"
+	InputTypeNumber  InputType = "number"
+	InputTypeInteger InputType = "integer"
+	InputTypeBoolean InputType = "boolean"
+	InputTypeObject  InputType = "object"
+	InputTypeArray   InputType = "array"
+)
+
+// Output declares a workflow output as an expr-lang expression over inputs and step outputs,
+// e.g. "steps.transform_data.output.result".
+type Output struct {
+	Name        string `yaml:"name" json:"name"`
+	Value       string `yaml:"value" json:"value"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
 }
 
 // Edge represents a connection between two steps in a workflow.
 type Edge struct {
-	From string `yaml:"from"`
-	To   string `yaml:"to"`
+	From string `yaml:"from" json:"from"`
+	To   string `yaml:"to" json:"to"`
 }
 
 // ExecutionStatus defines the status of a workflow execution.
@@ -38,6 +174,14 @@ const (
 	ExecutionStatusCompleted ExecutionStatus = "completed"
 	ExecutionStatusFailed    ExecutionStatus = "failed"
 	ExecutionStatusRetrying  ExecutionStatus = "retrying"
+	ExecutionStatusCancelled ExecutionStatus = "cancelled"
+	// ExecutionStatusWaiting marks an execution parked until a step receives its signal or times out.
+	ExecutionStatusWaiting ExecutionStatus = "waiting"
+	// ExecutionStatusCompensating marks a failed execution whose completed steps are being undone.
+	ExecutionStatusCompensating ExecutionStatus = "compensating"
+	// ExecutionStatusFinalizing marks an execution whose hooks are running; Execution.Outcome
+	// holds the status it finishes with.
+	ExecutionStatusFinalizing ExecutionStatus = "finalizing"
 )
 
 // StepStatus defines the status of a single step in an execution.
@@ -49,24 +193,64 @@ const (
 	StepStatusCompleted StepStatus = "completed"
 	StepStatusFailed    StepStatus = "failed"
 	StepStatusRetrying  StepStatus = "retrying"
+	StepStatusSkipped   StepStatus = "skipped"
+	StepStatusCancelled StepStatus = "cancelled"
+	StepStatusWaiting   StepStatus = "waiting"
 )
 
 // Execution represents a single, durable run of a workflow.
 type Execution struct {
-	ID         string                `json:"id"`
-	WorkflowID string                `json:"workflowId"`
-	Workflow   *Workflow             `json:"workflow"` // New field to store the workflow definition
-	Status     ExecutionStatus       `json:"status"`   // e.g., running, completed, failed, retrying
-	StepStates map[string]*StepState `json:"stepStates"`
-	CreatedAt  time.Time             `json:"createdAt"`
-	UpdatedAt  time.Time             `json:"updatedAt"`
+	ID         string                 `json:"id"`
+	WorkflowID string                 `json:"workflowId"`
+	Workflow   *Workflow              `json:"workflow"`          // New field to store the workflow definition
+	Status     ExecutionStatus        `json:"status"`            // e.g., running, completed, failed, retrying
+	Inputs     map[string]interface{} `json:"inputs,omitempty"`  // Workflow inputs, kept so resumes see the same values
+	Env        map[string]string      `json:"env,omitempty"`     // Environment variables interpolated into Workflow when it was loaded
+	Outputs    map[string]interface{} `json:"outputs,omitempty"` // Workflow outputs, set once the execution completes
+	StepStates map[string]*StepState  `json:"stepStates"`
+	CreatedAt  time.Time              `json:"createdAt"`
+	UpdatedAt  time.Time              `json:"updatedAt"`
+	// ParentExecutionID and ParentStepID link a sub-workflow execution to the step that started it.
+	ParentExecutionID string `json:"parentExecutionId,omitempty"`
+	ParentStepID      string `json:"parentStepId,omitempty"`
+	// Deadline is set from Workflow.Timeout on the first run, so resumes keep the original deadline.
+	Deadline time.Time `json:"deadline,omitempty"`
+	// MaxParallelism caps how many steps of this execution run at once. Zero defers to the engine.
+	MaxParallelism int `json:"maxParallelism,omitempty"`
+	// Outcome is the status a finalizing execution takes once its hooks have run. Finalizing
+	// executions without one are failed.
+	Outcome ExecutionStatus `json:"outcome,omitempty"`
+	// CompletionOrder lists step IDs in the order they completed; compensations run in reverse.
+	CompletionOrder []string `json:"completionOrder,omitempty"`
 }
 
 // StepState represents the state of a single step in an execution.
 type StepState struct {
-	Status      StepStatus             `json:"status"` // e.g., pending, running, completed, failed
-	Output      map[string]interface{} `json:"output,omitempty"`
-	Error       string                 `json:"error,omitempty"`
-	Attempts    int                    `json:"attempts"`
-	NextAttempt time.Time              `json:"nextAttempt,omitempty"` // For exponential backoff
+	Status           StepStatus             `json:"status"` // e.g., pending, running, completed, failed
+	Output           map[string]interface{} `json:"output,omitempty"`
+	Error            string                 `json:"error,omitempty"`
+	ErrorCode        string                 `json:"errorCode,omitempty"` // Class of the last error, e.g. "timeout"
+	Attempts         int                    `json:"attempts"`
+	NextAttempt      time.Time              `json:"nextAttempt,omitempty"`      // For exponential backoff
+	Items            []*ItemState           `json:"items,omitempty"`            // Per-item progress of a foreach step
+	ChildExecutionID string                 `json:"childExecutionId,omitempty"` // Execution started by a sub-workflow step
+	Compensation     *CompensationState     `json:"compensation,omitempty"`     // Set once the step is being undone
+	UsedFallback     bool                   `json:"usedFallback,omitempty"`     // Output came from the step's fallback tool
+	WakeAt           time.Time              `json:"wakeAt,omitempty"`           // When a timer fires, or a signal step stops waiting
+}
+
+// CompensationState represents the progress of undoing a completed step.
+type CompensationState struct {
+	Status   StepStatus             `json:"status"`
+	Output   map[string]interface{} `json:"output,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+	Attempts int                    `json:"attempts"`
+}
+
+// ItemState represents the state of a single item of a foreach step.
+type ItemState struct {
+	Status   StepStatus             `json:"status"`
+	Output   map[string]interface{} `json:"output,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+	Attempts int                    `json:"attempts"`
 }
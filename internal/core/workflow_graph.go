@@ -1,27 +1,117 @@
 package core
 
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EdgeList is the edges list of a workflow. In YAML either end of an edge may be a single
+// step ID or a list of them, e.g. `to: [save, notify]`, which stands for an edge from every
+// `from` step to every `to` step.
+type EdgeList []Edge
+
+// UnmarshalYAML expands edges with list-valued ends into one Edge per pair of steps.
+func (l *EdgeList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.SequenceNode {
+		return fmt.Errorf("line %d: edges must be a list", value.Line)
+	}
+	edges := make(EdgeList, 0, len(value.Content))
+	for _, item := range value.Content {
+		var edge struct {
+			From stepIDs `yaml:"from"`
+			To   stepIDs `yaml:"to"`
+		}
+		if err := item.Decode(&edge); err != nil {
+			return err
+		}
+		if len(edge.From) == 0 || len(edge.To) == 0 {
+			return fmt.Errorf("line %d: edge must set both from and to", item.Line)
+		}
+		for _, from := range edge.From {
+			for _, to := range edge.To {
+				edges = append(edges, Edge{From: from, To: to})
+			}
+		}
+	}
+	*l = edges
+	return nil
+}
+
+// stepIDs is one or more step IDs, written in YAML as a string or a list of strings.
+type stepIDs []string
+
+func (s *stepIDs) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*s = stepIDs{value.Value}
+		return nil
+	}
+	var ids []string
+	if err := value.Decode(&ids); err != nil {
+		return err
+	}
+	*s = ids
+	return nil
+}
+
+// AllEdges returns the workflow's dependency graph: its edges list followed by one edge per
+// depends_on entry of its steps, without duplicates. The engine and the validators use it
+// so that both forms of declaring dependencies behave the same.
+func (w *Workflow) AllEdges() []Edge {
+	edges := make([]Edge, 0, len(w.Edges))
+	seen := make(map[Edge]bool, len(w.Edges))
+	add := func(edge Edge) {
+		if !seen[edge] {
+			seen[edge] = true
+			edges = append(edges, edge)
+		}
+	}
+	for _, edge := range w.Edges {
+		add(edge)
+	}
+	for _, step := range w.Steps {
+		for _, dep := range step.DependsOn {
+			add(Edge{From: dep, To: step.ID})
+		}
+	}
+	return edges
+}
+
 // GetExecutableSteps identifies steps that are ready to be executed.
 // A step is executable if:
-// 1. It is in a Pending state.
-// 2. All its 'From' dependencies (predecessors) are in a Completed state.
-// 3. It has no 'From' dependencies (it's a root step).
+//  1. It has not started yet (no state or a Pending state), or it is Retrying and its NextAttempt has passed.
+//  2. All its 'From' dependencies (predecessors) are settled: Completed, Skipped, or Failed with on_error: continue.
+//  3. It has no 'From' dependencies (it's a root step).
+//
+// Steps are returned in their declaration order.
 func GetExecutableSteps(workflow *Workflow, stepStates map[string]*StepState) []string {
 	var executable []string
+	now := time.Now()
 
 	// Build a map of step ID to its incoming dependencies
 	dependencies := make(map[string]map[string]bool)
-	for _, edge := range workflow.Edges {
+	for _, edge := range workflow.AllEdges() {
 		if _, ok := dependencies[edge.To]; !ok {
 			dependencies[edge.To] = make(map[string]bool)
 		}
 		dependencies[edge.To][edge.From] = true
 	}
 
+	stepsByID := make(map[string]Step, len(workflow.Steps))
+	for _, step := range workflow.Steps {
+		stepsByID[step.ID] = step
+	}
+
 	for _, step := range workflow.Steps {
-		state, ok := stepStates[step.ID]
-		if !ok || state.Status != StepStatusPending {
-			// Skip if step state is not found or not pending
-			continue
+		if state, ok := stepStates[step.ID]; ok {
+			switch {
+			case state.Status == StepStatusPending:
+			case state.Status == StepStatusRetrying && !now.Before(state.NextAttempt):
+			default:
+				// Skip steps that are running, finished or still backing off
+				continue
+			}
 		}
 
 		// Check if all dependencies are met
@@ -29,7 +119,7 @@ func GetExecutableSteps(workflow *Workflow, stepStates map[string]*StepState) []
 		if preds, hasDeps := dependencies[step.ID]; hasDeps {
 			for predID := range preds {
 				predState, predOk := stepStates[predID]
-				if !predOk || predState.Status != StepStatusCompleted {
+				if !predOk || !isSettled(stepsByID[predID], predState) {
 					allDependenciesMet = false
 					break
 				}
@@ -43,3 +133,25 @@ func GetExecutableSteps(workflow *Workflow, stepStates map[string]*StepState) []
 
 	return executable
 }
+
+// dependenciesOf returns the IDs of the steps that stepID depends on, in AllEdges order.
+func dependenciesOf(workflow *Workflow, stepID string) []string {
+	var deps []string
+	for _, edge := range workflow.AllEdges() {
+		if edge.To == stepID {
+			deps = append(deps, edge.From)
+		}
+	}
+	return deps
+}
+
+// isSuccessful reports whether a step with this status has finished without failing.
+func (s StepStatus) isSuccessful() bool {
+	return s == StepStatusCompleted || s == StepStatusSkipped
+}
+
+// isSettled reports whether a step no longer holds up its dependants or the completion of
+// its execution: it finished without failing, or failed with on_error: continue.
+func isSettled(step Step, state *StepState) bool {
+	return state.Status.isSuccessful() || (state.Status == StepStatusFailed && step.OnError == OnErrorContinue)
+}
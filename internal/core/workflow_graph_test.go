@@ -119,4 +119,57 @@ func TestGetExecutableSteps(t *testing.T) {
 	stepStates2["C"].Status = StepStatusCompleted
 	executable = GetExecutableSteps(&workflow2, stepStates2)
 	assertEmpty(t, executable, "Expected no executable steps when all are completed in linear workflow")
-}
\ No newline at end of file
+}
+
+func TestGetExecutableSteps_SkippedDependencies(t *testing.T) {
+	// A -> B -> C
+	workflow := Workflow{
+		ID: "test-workflow-skipped",
+		Steps: []Step{
+			{ID: "A", Tool: "toolA"},
+			{ID: "B", Tool: "toolB"},
+			{ID: "C", Tool: "toolC"},
+		},
+		Edges: []Edge{
+			{From: "A", To: "B"},
+			{From: "B", To: "C"},
+		},
+	}
+	stepStates := map[string]*StepState{
+		"A": {Status: StepStatusCompleted},
+		"B": {Status: StepStatusSkipped},
+	}
+
+	// C has no state yet and its only dependency is terminal, so it is ready.
+	executable := GetExecutableSteps(&workflow, stepStates)
+	assertElementsMatch(t, []string{"C"}, executable, "Expected C to be executable after B is skipped")
+
+	stepStates["C"] = &StepState{Status: StepStatusSkipped}
+	executable = GetExecutableSteps(&workflow, stepStates)
+	assertEmpty(t, executable, "Expected no executable steps when all are completed or skipped")
+}
+
+func TestGetExecutableSteps_DependsOn(t *testing.T) {
+	workflow := &Workflow{
+		Steps: []Step{
+			{ID: "a"},
+			{ID: "b", DependsOn: []string{"a"}},
+			{ID: "c"},
+		},
+		Edges: []Edge{{From: "c", To: "b"}},
+	}
+
+	executable := GetExecutableSteps(workflow, map[string]*StepState{})
+	assertElementsMatch(t, []string{"a", "c"}, executable, "initially")
+
+	executable = GetExecutableSteps(workflow, map[string]*StepState{
+		"a": {Status: StepStatusCompleted},
+	})
+	assertElementsMatch(t, []string{"c"}, executable, "after a completed")
+
+	executable = GetExecutableSteps(workflow, map[string]*StepState{
+		"a": {Status: StepStatusCompleted},
+		"c": {Status: StepStatusCompleted},
+	})
+	assertElementsMatch(t, []string{"b"}, executable, "after a and c completed")
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ResolveInputs checks inputs against the workflow's declared inputs and returns a copy
+// with defaults applied. Workflows that declare no inputs accept any inputs unchanged.
+func (w *Workflow) ResolveInputs(inputs map[string]interface{}) (map[string]interface{}, error) {
+	if len(w.Inputs) == 0 {
+		return inputs, nil
+	}
+	resolved, err := ResolveDeclared(w.Inputs, inputs, "input")
+	if err != nil {
+		return nil, fmt.Errorf("invalid inputs of workflow %s: %w", w.ID, err)
+	}
+	return resolved, nil
+}
+
+// ResolveDeclared checks values against declarations such as a workflow's inputs and
+// returns a copy with defaults applied, leaving out optional values that are not set. Each
+// problem is a separate error naming the value by kind, e.g. "input" or "param": a required
+// value that is not set, a value of the wrong type or one that is not declared.
+func ResolveDeclared(declarations []Input, values map[string]interface{}, kind string) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(declarations))
+	declared := make(map[string]bool, len(declarations))
+	var errs []error
+	for _, input := range declarations {
+		declared[input.Name] = true
+		value, ok := values[input.Name]
+		if !ok || value == nil {
+			switch {
+			case input.Default != nil:
+				value = input.Default
+			case input.Required:
+				errs = append(errs, fmt.Errorf("%s %q is required", kind, input.Name))
+				continue
+			default:
+				continue
+			}
+		}
+		if !input.Type.Accepts(value) {
+			errs = append(errs, fmt.Errorf("%s %q must be of type %s, got %T", kind, input.Name, input.Type, value))
+			continue
+		}
+		resolved[input.Name] = value
+	}
+
+	var unknown []string
+	for name := range values {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, fmt.Errorf("%s %q is not declared", kind, name))
+	}
+	return resolved, errors.Join(errs...)
+}
+
+// Accepts reports whether value is valid for the input type.
+func (t InputType) Accepts(value interface{}) bool {
+	switch t {
+	case "":
+		return true
+	case InputTypeString:
+		_, ok := value.(string)
+		return ok
+	case InputTypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case InputTypeNumber:
+		_, ok := toFloat(value)
+		return ok
+	case InputTypeInteger:
+		f, ok := toFloat(value)
+		return ok && f == math.Trunc(f)
+	case InputTypeObject:
+		_, ok := value.(map[string]interface{})
+		return ok
+	case InputTypeArray:
+		_, ok := value.([]interface{})
+		return ok
+	default:
+		return false
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// evalOutputs evaluates the workflow's declared outputs against data. Workflows that
+// declare no outputs expose the output of every completed step, keyed by step ID.
+func evalOutputs(workflow *Workflow, execution *Execution, data map[string]interface{}) (map[string]interface{}, error) {
+	outputs := make(map[string]interface{})
+	if len(workflow.Outputs) == 0 {
+		for _, step := range workflow.Steps {
+			if stepState, ok := execution.StepStates[step.ID]; ok && stepState.Status == StepStatusCompleted {
+				outputs[step.ID] = stepState.Output
+			}
+		}
+		return outputs, nil
+	}
+
+	for _, output := range workflow.Outputs {
+		value, err := evalExpression(output.Value, data)
+		if err != nil {
+			return nil, fmt.Errorf("output %q: %w", output.Name, err)
+		}
+		outputs[output.Name] = value
+	}
+	return outputs, nil
+}
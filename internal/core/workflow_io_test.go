@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWorkflow_ResolveInputs(t *testing.T) {
+	workflow := &Workflow{
+		ID: "wf-inputs",
+		Inputs: []Input{
+			{Name: "source_id", Type: InputTypeString, Required: true},
+			{Name: "limit", Type: InputTypeInteger, Default: 100},
+			{Name: "ratio", Type: InputTypeNumber},
+			{Name: "tags", Type: InputTypeArray},
+			{Name: "anything"},
+		},
+	}
+
+	t.Run("defaults applied", func(t *testing.T) {
+		resolved, err := workflow.ResolveInputs(map[string]interface{}{"source_id": "s-1", "ratio": 0.5})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resolved["source_id"] != "s-1" || resolved["limit"] != 100 || resolved["ratio"] != 0.5 {
+			t.Errorf("unexpected resolved inputs %v", resolved)
+		}
+		if _, ok := resolved["tags"]; ok {
+			t.Errorf("expected optional input without default to be absent, got %v", resolved["tags"])
+		}
+	})
+
+	t.Run("JSON numbers are valid integers", func(t *testing.T) {
+		if _, err := workflow.ResolveInputs(map[string]interface{}{"source_id": "s-1", "limit": float64(5)}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("invalid inputs", func(t *testing.T) {
+		_, err := workflow.ResolveInputs(map[string]interface{}{
+			"limit":   2.5,
+			"tags":    "not-a-list",
+			"unknown": true,
+		})
+		if err == nil {
+			t.Fatalf("expected an error, got none")
+		}
+		for _, want := range []string{
+			`input "source_id" is required`,
+			`input "limit" must be of type integer`,
+			`input "tags" must be of type array`,
+			`input "unknown" is not declared`,
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected error to contain %q, got %q", want, err.Error())
+			}
+		}
+	})
+
+	t.Run("undeclared inputs pass through", func(t *testing.T) {
+		inputs := map[string]interface{}{"free": "form"}
+		resolved, err := (&Workflow{ID: "wf-free"}).ResolveInputs(inputs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resolved["free"] != "form" {
+			t.Errorf("expected inputs to be unchanged, got %v", resolved)
+		}
+	})
+}
+
+func TestEngine_Execute_DeclaredOutputs(t *testing.T) {
+	dispatcher := &MockDispatcher{
+		DispatchFunc: func(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"result": []interface{}{params["value"], params["value"]}}, nil
+		},
+	}
+	engine := NewEngine(dispatcher, &MockStore{})
+
+	workflow := &Workflow{
+		ID:     "wf-outputs",
+		Inputs: []Input{{Name: "value", Type: InputTypeNumber, Default: 21}},
+		Outputs: []Output{
+			{Name: "doubled", Value: "steps.transform.output.result"},
+			{Name: "count", Value: "len(transform.output.result) + inputs.value"},
+		},
+		Steps: []Step{{ID: "transform", Tool: "sire:local/data.transform", Params: map[string]interface{}{"value": "{{ .inputs.value }}"}}},
+	}
+	execution := &Execution{ID: "exec-outputs-1", StepStates: make(map[string]*StepState)}
+
+	execResult, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	doubled, ok := execResult.Outputs["doubled"].([]interface{})
+	if !ok || len(doubled) != 2 || doubled[0] != 21 {
+		t.Errorf("expected doubled output %v, got %#v", []interface{}{21, 21}, execResult.Outputs["doubled"])
+	}
+	if execResult.Outputs["count"] != 23 {
+		t.Errorf("expected count output %d, got %#v", 23, execResult.Outputs["count"])
+	}
+}
+
+func TestEngine_Execute_RejectsInvalidInputs(t *testing.T) {
+	engine := NewEngine(&MockDispatcher{}, &MockStore{})
+	workflow := &Workflow{
+		ID:     "wf-required",
+		Inputs: []Input{{Name: "source_id", Required: true}},
+		Steps:  []Step{{ID: "a", Tool: "sire:local/a"}},
+	}
+	execution := &Execution{ID: "exec-required-1", StepStates: make(map[string]*StepState)}
+
+	execResult, err := engine.Execute(context.Background(), execution, workflow, nil)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if execResult.Status != ExecutionStatusFailed {
+		t.Errorf("expected status %q, got %q", ExecutionStatusFailed, execResult.Status)
+	}
+	if len(execResult.StepStates) != 0 {
+		t.Errorf("expected no step to run, got %v", execResult.StepStates)
+	}
+}
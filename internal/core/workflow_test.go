@@ -78,3 +78,66 @@ edges:
 		t.Errorf("expected edge To %q, got %q", "step2", wf.Edges[0].To)
 	}
 }
+
+func TestWorkflow_YAMLUnmarshal_ListEdgesAndDependsOn(t *testing.T) {
+	yamlData := `
+id: fan-out
+steps:
+  - id: fetch
+    tool: "sire:local/test.fetch"
+  - id: save
+    tool: "sire:local/test.save"
+  - id: notify
+    tool: "sire:local/test.notify"
+  - id: report
+    tool: "sire:local/test.report"
+    depends_on: [save, notify]
+edges:
+  - from: fetch
+    to: [save, notify]
+  - from: [save]
+    to: report
+`
+	var wf Workflow
+	if err := yaml.Unmarshal([]byte(yamlData), &wf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantEdges := EdgeList{{From: "fetch", To: "save"}, {From: "fetch", To: "notify"}, {From: "save", To: "report"}}
+	if len(wf.Edges) != len(wantEdges) {
+		t.Fatalf("expected edges %v, got %v", wantEdges, wf.Edges)
+	}
+	for i, edge := range wantEdges {
+		if wf.Edges[i] != edge {
+			t.Errorf("expected edge %d to be %v, got %v", i, edge, wf.Edges[i])
+		}
+	}
+
+	// depends_on adds to the edges list; the duplicate save -> report edge appears once.
+	allEdges := wf.AllEdges()
+	wantAll := []Edge{{From: "fetch", To: "save"}, {From: "fetch", To: "notify"}, {From: "save", To: "report"}, {From: "notify", To: "report"}}
+	if len(allEdges) != len(wantAll) {
+		t.Fatalf("expected all edges %v, got %v", wantAll, allEdges)
+	}
+	for i, edge := range wantAll {
+		if allEdges[i] != edge {
+			t.Errorf("expected edge %d to be %v, got %v", i, edge, allEdges[i])
+		}
+	}
+}
+
+func TestWorkflow_YAMLUnmarshal_IncompleteEdge(t *testing.T) {
+	yamlData := `
+id: broken
+steps:
+  - id: a
+    tool: "sire:local/test.a"
+edges:
+  - from: a
+`
+	var wf Workflow
+	err := yaml.Unmarshal([]byte(yamlData), &wf)
+	if err == nil || err.Error() != "line 7: edge must set both from and to" {
+		t.Errorf("expected an incomplete edge error, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+// Package graph renders workflows as Graphviz DOT or Mermaid diagrams, optionally coloured
+// by the step statuses of an execution.
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sire-run/sire/internal/core"
+)
+
+// Format is a diagram language.
+type Format string
+
+const (
+	// FormatDOT renders a Graphviz digraph, e.g. for `dot -Tsvg`.
+	FormatDOT Format = "dot"
+	// FormatMermaid renders a Mermaid flowchart, which GitHub displays in Markdown.
+	FormatMermaid Format = "mermaid"
+)
+
+// statusColors maps step statuses to node fill colours.
+var statusColors = map[core.StepStatus]string{
+	core.StepStatusPending:   "#ffffff",
+	core.StepStatusRunning:   "#90caf9",
+	core.StepStatusCompleted: "#a5d6a7",
+	core.StepStatusFailed:    "#ef9a9a",
+	core.StepStatusRetrying:  "#ffcc80",
+	core.StepStatusSkipped:   "#e0e0e0",
+	core.StepStatusCancelled: "#bdbdbd",
+	core.StepStatusWaiting:   "#fff59d",
+}
+
+// node is a step as drawn in a diagram.
+type node struct {
+	id     string
+	lines  []string        // Label lines: the step ID, what the step does and its status
+	status core.StepStatus // Empty without an execution
+}
+
+// Render writes the diagram of workflow's steps and edges, including depends_on entries,
+// to w. If execution is not nil, every node shows and is coloured by the status of its
+// step state; steps without a state are pending.
+func Render(w io.Writer, workflow *core.Workflow, execution *core.Execution, format Format) error {
+	nodes := make([]node, 0, len(workflow.Steps))
+	for _, step := range workflow.Steps {
+		n := node{id: step.ID, lines: []string{step.ID, describe(step)}}
+		if execution != nil {
+			n.status = core.StepStatusPending
+			if stepState, ok := execution.StepStates[step.ID]; ok && stepState.Status != "" {
+				n.status = stepState.Status
+			}
+			n.lines = append(n.lines, string(n.status))
+		}
+		nodes = append(nodes, n)
+	}
+
+	switch format {
+	case FormatDOT:
+		return renderDOT(w, workflow, nodes)
+	case FormatMermaid:
+		return renderMermaid(w, workflow, nodes)
+	default:
+		return fmt.Errorf("unknown graph format %q (expected %s or %s)", format, FormatDOT, FormatMermaid)
+	}
+}
+
+// describe returns what a step does: its tool, sub-workflow, signal or timer.
+func describe(step core.Step) string {
+	switch {
+	case step.Workflow != "":
+		return "workflow: " + step.Workflow
+	case step.Signal != nil:
+		return "signal"
+	case step.Sleep != "":
+		return "sleep: " + step.Sleep
+	case step.WaitUntil != "":
+		return "wait_until: " + step.WaitUntil
+	default:
+		return step.Tool
+	}
+}
+
+// renderDOT writes a Graphviz digraph.
+func renderDOT(w io.Writer, workflow *core.Workflow, nodes []node) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(workflow.ID))
+	b.WriteString("  rankdir=LR;\n")
+	fmt.Fprintf(&b, "  node [shape=box, style=\"rounded,filled\", fillcolor=%q];\n", statusColors[core.StepStatusPending])
+	known := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		known[n.id] = true
+		fmt.Fprintf(&b, "  %s [label=%s", dotQuote(n.id), dotQuote(strings.Join(n.lines, "\n")))
+		if color, ok := statusColors[n.status]; ok {
+			fmt.Fprintf(&b, ", fillcolor=%q", color)
+		}
+		b.WriteString("];\n")
+	}
+	for _, edge := range workflow.AllEdges() {
+		if !known[edge.From] || !known[edge.To] {
+			continue // Edges to unknown steps are reported by validation, not drawn
+		}
+		fmt.Fprintf(&b, "  %s -> %s;\n", dotQuote(edge.From), dotQuote(edge.To))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote returns s as a quoted DOT ID, with newlines as line breaks.
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+// renderMermaid writes a Mermaid flowchart. Nodes are named n0, n1, ... in declaration
+// order, since step IDs may contain characters Mermaid does not allow in names.
+func renderMermaid(w io.Writer, workflow *core.Workflow, nodes []node) error {
+	names := make(map[string]string, len(nodes))
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, n := range nodes {
+		names[n.id] = fmt.Sprintf("n%d", i)
+		lines := make([]string, len(n.lines))
+		for j, line := range n.lines {
+			lines[j] = mermaidEscape(line)
+		}
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", names[n.id], strings.Join(lines, "<br/>"))
+	}
+	for _, edge := range workflow.AllEdges() {
+		from, to := names[edge.From], names[edge.To]
+		if from == "" || to == "" {
+			continue // Edges to unknown steps are reported by validation, not drawn
+		}
+		fmt.Fprintf(&b, "  %s --> %s\n", from, to)
+	}
+
+	// One class per status in use, in a fixed order.
+	for _, status := range []core.StepStatus{
+		core.StepStatusPending, core.StepStatusRunning, core.StepStatusCompleted, core.StepStatusFailed,
+		core.StepStatusRetrying, core.StepStatusSkipped, core.StepStatusCancelled, core.StepStatusWaiting,
+	} {
+		var members []string
+		for _, n := range nodes {
+			if n.status == status {
+				members = append(members, names[n.id])
+			}
+		}
+		if len(members) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "  classDef %s fill:%s\n", status, statusColors[status])
+		fmt.Fprintf(&b, "  class %s %s\n", strings.Join(members, ","), status)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidEscape escapes the characters that end or break a quoted Mermaid label.
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(s)
+}
@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sire-run/sire/internal/core"
+)
+
+func testWorkflow() *core.Workflow {
+	return &core.Workflow{
+		ID: "review",
+		Steps: []core.Step{
+			{ID: "fetch", Tool: "sire:local/http.request"},
+			{ID: "approve", Signal: &core.SignalSpec{}},
+			{ID: "publish", Tool: "mcp:http://cms.internal/rpc#pages.publish", DependsOn: []string{"approve"}},
+		},
+		Edges: core.EdgeList{{From: "fetch", To: "approve"}},
+	}
+}
+
+func TestRender_DOT(t *testing.T) {
+	var b strings.Builder
+	if err := Render(&b, testWorkflow(), nil, FormatDOT); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `digraph "review" {
+  rankdir=LR;
+  node [shape=box, style="rounded,filled", fillcolor="#ffffff"];
+  "fetch" [label="fetch\nsire:local/http.request"];
+  "approve" [label="approve\nsignal"];
+  "publish" [label="publish\nmcp:http://cms.internal/rpc#pages.publish"];
+  "fetch" -> "approve";
+  "approve" -> "publish";
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("unexpected DOT output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRender_DOT_Execution(t *testing.T) {
+	execution := &core.Execution{StepStates: map[string]*core.StepState{
+		"fetch":   {Status: core.StepStatusCompleted},
+		"approve": {Status: core.StepStatusFailed},
+	}}
+	var b strings.Builder
+	if err := Render(&b, testWorkflow(), execution, FormatDOT); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		`"fetch" [label="fetch\nsire:local/http.request\ncompleted", fillcolor="#a5d6a7"];`,
+		`"approve" [label="approve\nsignal\nfailed", fillcolor="#ef9a9a"];`,
+		`"publish" [label="publish\nmcp:http://cms.internal/rpc#pages.publish\npending", fillcolor="#ffffff"];`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestRender_Mermaid(t *testing.T) {
+	execution := &core.Execution{StepStates: map[string]*core.StepState{
+		"fetch":   {Status: core.StepStatusCompleted},
+		"approve": {Status: core.StepStatusCompleted},
+	}}
+	var b strings.Builder
+	if err := Render(&b, testWorkflow(), execution, FormatMermaid); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `flowchart LR
+  n0["fetch<br/>sire:local/http.request<br/>completed"]
+  n1["approve<br/>signal<br/>completed"]
+  n2["publish<br/>mcp:http://cms.internal/rpc#pages.publish<br/>pending"]
+  n0 --> n1
+  n1 --> n2
+  classDef pending fill:#ffffff
+  class n2 pending
+  classDef completed fill:#a5d6a7
+  class n0,n1 completed
+`
+	if got := b.String(); got != want {
+		t.Errorf("unexpected Mermaid output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRender_Escaping(t *testing.T) {
+	workflow := &core.Workflow{
+		ID:    `say "hi"`,
+		Steps: []core.Step{{ID: "wait", WaitUntil: `{{ .inputs.at }}`}},
+	}
+	var b strings.Builder
+	if err := Render(&b, workflow, nil, FormatDOT); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(b.String(), `digraph "say \"hi\"" {`) {
+		t.Errorf("expected the workflow ID to be escaped, got:\n%s", b.String())
+	}
+
+	b.Reset()
+	workflow.Steps[0].ID = `a"b`
+	if err := Render(&b, workflow, nil, FormatMermaid); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(b.String(), `n0["a#quot;b<br/>wait_until: {{ .inputs.at }}"]`) {
+		t.Errorf("expected the label to be escaped, got:\n%s", b.String())
+	}
+}
+
+func TestRender_UnknownFormat(t *testing.T) {
+	var b strings.Builder
+	if err := Render(&b, testWorkflow(), nil, "svg"); err == nil || !strings.Contains(err.Error(), `unknown graph format "svg"`) {
+		t.Errorf("expected an unknown format error, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package loader
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envRef matches ${env:<name>} and ${env:<name>:-<default>}.
+var envRef = regexp.MustCompile(`\$\{env:([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// envName matches the variable names of env files.
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Env is the environment that the ${env:<name>} references of workflow files are
+// resolved against: its own variables, e.g. read from an env file, then the process
+// environment. It records the variables it resolves so that an execution can keep them.
+// It is safe for concurrent use, e.g. by the loads of a run's sub-workflows.
+type Env struct {
+	vars   map[string]string
+	lookup func(name string) (string, bool)
+
+	mu       sync.Mutex
+	resolved map[string]string
+}
+
+// NewEnv creates an Env in which vars take precedence over the process environment.
+func NewEnv(vars map[string]string) *Env {
+	return &Env{vars: vars, lookup: os.LookupEnv, resolved: make(map[string]string)}
+}
+
+// Lookup returns the value of the variable name and records it, or reports that it is
+// not set.
+func (e *Env) Lookup(name string) (string, bool) {
+	value, ok := e.vars[name]
+	if !ok {
+		value, ok = e.lookup(name)
+	}
+	if ok {
+		e.mu.Lock()
+		e.resolved[name] = value
+		e.mu.Unlock()
+	}
+	return value, ok
+}
+
+// Resolved returns the variables resolved so far, by name. Defaults used for unset
+// variables are not included.
+func (e *Env) Resolved() map[string]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return maps.Clone(e.resolved)
+}
+
+// ReadEnvFile reads the variables of a .env file: NAME=VALUE lines, optionally prefixed
+// with `export`, where VALUE may be single- or double-quoted. Blank lines and lines
+// starting with # are ignored, as is a # comment after an unquoted value.
+func ReadEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(text, "export "), "=")
+		name = strings.TrimSpace(name)
+		if !ok || !envName.MatchString(name) {
+			return nil, &Error{File: path, Line: line, Err: errors.New("expected NAME=VALUE")}
+		}
+		value, err := envValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, &Error{File: path, Line: line, Err: fmt.Errorf("variable %s: %w", name, err)}
+		}
+		vars[name] = value
+	}
+	return vars, scanner.Err()
+}
+
+// envValue unquotes the value of an env file variable.
+func envValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", errors.New("invalid double-quoted value")
+		}
+		return unquoted, nil
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", errors.New("unterminated single-quoted value")
+		}
+		return value[1 : len(value)-1], nil
+	default:
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		return value, nil
+	}
+}
+
+// Interpolate replaces the ${env:<name>} and ${env:<name>:-<default>} references in the
+// scalar values of the workflow document in doc, read from path, with the variables of
+// env. As in a shell, the default is used when the variable is unset or empty. A plain
+// (unquoted) YAML scalar that is a single reference takes the type of its value, e.g.
+// `concurrency: ${env:WORKERS}` is an integer; other references are interpolated as
+// strings. This includes every reference in a JSON file, whose strings are all quoted, so
+// numeric and boolean fields of JSON workflows cannot be set from the environment.
+// Variables that are unset and have no default are reported as *Error values.
+func Interpolate(path string, doc *yaml.Node, env *Env) error {
+	var errs []error
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		switch node.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range node.Content {
+				walk(child)
+			}
+		case yaml.MappingNode:
+			for i := 1; i < len(node.Content); i += 2 {
+				walk(node.Content[i])
+			}
+		case yaml.ScalarNode:
+			if err := interpolateScalar(node, env); err != nil {
+				errs = append(errs, &Error{File: path, Line: node.Line, Column: node.Column, Err: err})
+			}
+		}
+	}
+	walk(doc)
+	return errors.Join(errs...)
+}
+
+// interpolateScalar replaces the ${env:<name>} references in a scalar node.
+func interpolateScalar(node *yaml.Node, env *Env) error {
+	matches := envRef.FindAllStringSubmatchIndex(node.Value, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	whole := len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(node.Value)
+	var missing []string
+	value := envRef.ReplaceAllStringFunc(node.Value, func(ref string) string {
+		m := envRef.FindStringSubmatch(ref)
+		if value, ok := env.Lookup(m[1]); ok && (value != "" || m[2] == "") {
+			return value
+		}
+		if m[2] != "" {
+			return strings.TrimPrefix(m[2], ":-")
+		}
+		missing = append(missing, m[1])
+		return ref
+	})
+	if len(missing) > 0 {
+		return fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+
+	node.Value = value
+	if whole && node.Style == 0 {
+		node.Tag = "" // Resolved from the value, as if it had been written in place
+	} else {
+		node.Tag = "!!str"
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package loader
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad_Env(t *testing.T) {
+	t.Setenv("SIRE_TEST_REGION", "eu-west-1")
+	t.Setenv("SIRE_TEST_WORKERS", "from the process")
+	dir := writeFiles(t, map[string]string{
+		"workflow.yml": `id: deploy-${env:SIRE_TEST_REGION}
+steps:
+  - id: ship
+    tool: sire:local/ship.run
+    concurrency: ${env:SIRE_TEST_WORKERS}
+    params:
+      token: "${env:SIRE_TEST_TOKEN}"
+      url: https://${env:SIRE_TEST_HOST:-example.com}/deploy
+      dry_run: ${env:SIRE_TEST_DRY_RUN:-false}
+`,
+	})
+
+	env := NewEnv(map[string]string{"SIRE_TEST_WORKERS": "4", "SIRE_TEST_TOKEN": "0123"})
+	workflow, err := Load(filepath.Join(dir, "workflow.yml"), WithEnv(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if workflow.ID != "deploy-eu-west-1" {
+		t.Errorf("expected the process environment to be used, got ID %q", workflow.ID)
+	}
+	step := workflow.Steps[0]
+	if step.Concurrency != 4 {
+		t.Errorf("expected the env file variable to override the process and be an integer, got %d", step.Concurrency)
+	}
+	if got := step.Params["token"]; got != "0123" {
+		t.Errorf("expected a quoted reference to stay a string, got %#v", got)
+	}
+	if got := step.Params["url"]; got != "https://example.com/deploy" {
+		t.Errorf("expected the default to be interpolated, got %v", got)
+	}
+	if got := step.Params["dry_run"]; got != false {
+		t.Errorf("expected an unquoted default to be a boolean, got %#v", got)
+	}
+
+	resolved := env.Resolved()
+	want := map[string]string{"SIRE_TEST_REGION": "eu-west-1", "SIRE_TEST_WORKERS": "4", "SIRE_TEST_TOKEN": "0123"}
+	if len(resolved) != len(want) {
+		t.Errorf("expected the resolved variables %v, got %v", want, resolved)
+	}
+	for name, value := range want {
+		if resolved[name] != value {
+			t.Errorf("expected %s=%q to be recorded, got %q", name, value, resolved[name])
+		}
+	}
+}
+
+func TestLoad_EnvJSON(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"strings.json": `{"id": "wf", "steps": [
+  {"id": "ship", "tool": "sire:local/ship.run", "params": {"token": "${env:SIRE_TEST_TOKEN}"}}
+]}`,
+		"number.json": `{"id": "wf", "steps": [
+  {"id": "ship", "tool": "sire:local/ship.run",
+   "concurrency": "${env:SIRE_TEST_WORKERS:-3}"}
+]}`,
+	})
+	env := NewEnv(map[string]string{"SIRE_TEST_TOKEN": "0123"})
+
+	// JSON strings are quoted, so references in them always interpolate as strings.
+	workflow, err := Load(filepath.Join(dir, "strings.json"), WithEnv(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := workflow.Steps[0].Params["token"]; got != "0123" {
+		t.Errorf("expected the reference to stay a string, got %#v", got)
+	}
+
+	// Numeric and boolean fields therefore cannot be set from the environment in JSON.
+	_, err = Load(filepath.Join(dir, "number.json"), WithEnv(env))
+	if want := "number.json:3: cannot unmarshal !!str `3` into int"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("expected error %v to contain %q", err, want)
+	}
+}
+
+func TestLoad_EnvErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"secrets.yml": `templates:
+  call:
+    env: [SIRE_TEST_API_KEY, SIRE_TEST_API_URL]
+    step:
+      tool: mcp:http://api.internal/rpc#call.run
+      params:
+        key: ${env:SIRE_TEST_API_KEY}
+`,
+		"workflow.json": `{"id": "wf", "import": ["secrets.yml"], "steps": [
+  {"id": "call", "uses": "secrets/call"},
+  {"id": "other", "tool": "sire:local/test.run",
+   "params": {"value": "${env:SIRE_TEST_MISSING}"}}
+]}`,
+	})
+
+	_, err := Load(filepath.Join(dir, "workflow.json"), WithEnv(NewEnv(map[string]string{"SIRE_TEST_API_URL": "x"})))
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	want := `workflow.json:2:26: step call: template secrets/call requires environment variable SIRE_TEST_API_KEY, which is not set`
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("expected error %q to contain %q", err, want)
+	}
+
+	_, err = Load(filepath.Join(dir, "workflow.json"), WithEnv(NewEnv(map[string]string{"SIRE_TEST_API_KEY": "k", "SIRE_TEST_API_URL": "x"})))
+	want = `workflow.json:4:24: environment variable SIRE_TEST_MISSING is not set`
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("expected error %v to contain %q", err, want)
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		".env": `# Deployment settings
+REGION=eu-west-1
+export TOKEN="s3cr3t \"quoted\""
+LITERAL='${not} #interpolated'
+EMPTY=
+WORKERS=4 # per region
+`,
+		"broken.env": "REGION=eu\nnot a variable\n",
+	})
+
+	vars, err := ReadEnvFile(filepath.Join(dir, ".env"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, value := range map[string]string{
+		"REGION":  "eu-west-1",
+		"TOKEN":   `s3cr3t "quoted"`,
+		"LITERAL": "${not} #interpolated",
+		"EMPTY":   "",
+		"WORKERS": "4",
+	} {
+		if got, ok := vars[name]; !ok || got != value {
+			t.Errorf("expected %s=%q, got %q", name, value, got)
+		}
+	}
+
+	_, err = ReadEnvFile(filepath.Join(dir, "broken.env"))
+	var loaderErr *Error
+	if !errors.As(err, &loaderErr) || loaderErr.Line != 2 {
+		t.Errorf("expected an *Error on line 2, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package loader
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// nodeType is the type of yaml.Node fields, which take any value.
+var nodeType = reflect.TypeOf(yaml.Node{})
+
+// reflectType returns the type out points to.
+func reflectType(out interface{}) reflect.Type {
+	return reflect.TypeOf(out).Elem()
+}
+
+// unknownFields returns a message, in the "line N, column M: ..." form, for
+// every mapping key under node that matches no field of the struct it decodes into, as
+// yaml.Decoder.KnownFields would. Values of the wrong kind are left to the decoder.
+func unknownFields(node *yaml.Node, t reflect.Type) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return unknownFields(node.Content[0], t)
+	case yaml.AliasNode:
+		return unknownFields(node.Alias, t)
+	}
+	if t == nodeType {
+		return nil
+	}
+
+	var messages []string
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := structFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Tag == "!!merge" {
+				messages = append(messages, unknownFields(value, t)...)
+				continue
+			}
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				messages = append(messages, unknownFieldMessage(key, fields))
+				continue
+			}
+			messages = append(messages, unknownFields(value, fieldType)...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			messages = append(messages, unknownFields(node.Content[i], t.Elem())...)
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for _, item := range node.Content {
+			messages = append(messages, unknownFields(item, t.Elem())...)
+		}
+	}
+	return messages
+}
+
+// structFields returns the types of t's fields by YAML key, including those of inlined
+// structs.
+func structFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(options, "inline") {
+			for key, fieldType := range structFields(f.Type) {
+				fields[key] = fieldType
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// unknownFieldMessage reports key as unknown, suggesting the closest field name if any is
+// close enough to be a typo.
+func unknownFieldMessage(key *yaml.Node, fields map[string]reflect.Type) string {
+	message := fmt.Sprintf("line %d, column %d: unknown field %q", key.Line, key.Column, key.Value)
+	best, bestDistance := "", len(key.Value)/2+1
+	for name := range fields {
+		d := editDistance(key.Value, name)
+		if d < bestDistance || (d == bestDistance && best != "" && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+	if best != "" {
+		message += fmt.Sprintf(" (did you mean %q?)", best)
+	}
+	return message
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
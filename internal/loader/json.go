@@ -0,0 +1,127 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// jsonParser builds a YAML node tree from JSON tokens, positioning every node at the
+// line and column of its token.
+type jsonParser struct {
+	data       []byte
+	dec        *json.Decoder
+	lineStarts []int // Offsets at which each line of data starts
+}
+
+// parseJSON parses a JSON document into a YAML document node. Errors are in the "line N:
+// ..." form of yaml.v3 errors.
+func parseJSON(data []byte) (*yaml.Node, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	p := &jsonParser{data: data, dec: dec, lineStarts: []int{0}}
+	for i, b := range data {
+		if b == '\n' {
+			p.lineStarts = append(p.lineStarts, i+1)
+		}
+	}
+
+	value, err := p.value()
+	if err != nil {
+		return nil, p.syntaxError(err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		line, _ := p.position(int(dec.InputOffset()))
+		return nil, fmt.Errorf("line %d: unexpected data after the top-level JSON value", line)
+	}
+	return &yaml.Node{Kind: yaml.DocumentNode, Line: 1, Column: 1, Content: []*yaml.Node{value}}, nil
+}
+
+// value parses the next JSON value.
+func (p *jsonParser) value() (*yaml.Node, error) {
+	line, column := p.position(p.tokenStart())
+	token, err := p.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	node := &yaml.Node{Line: line, Column: column}
+
+	switch token := token.(type) {
+	case json.Delim:
+		node.Kind, node.Tag = yaml.SequenceNode, "!!seq"
+		if token == '{' {
+			node.Kind, node.Tag = yaml.MappingNode, "!!map"
+		}
+		for p.dec.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, key)
+			}
+			item, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, item)
+		}
+		if _, err := p.dec.Token(); err != nil { // The closing delimiter
+			return nil, err
+		}
+		return node, nil
+	case string:
+		node.Kind, node.Tag, node.Style, node.Value = yaml.ScalarNode, "!!str", yaml.DoubleQuotedStyle, token
+	case json.Number:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!float", token.String()
+		if _, err := strconv.ParseInt(token.String(), 10, 64); err == nil {
+			node.Tag = "!!int"
+		}
+	case bool:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!bool", strconv.FormatBool(token)
+	case nil:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!null", "null"
+	}
+	return node, nil
+}
+
+// tokenStart returns the offset of the next token, skipping the whitespace and separators
+// that follow the previous one.
+func (p *jsonParser) tokenStart() int {
+	offset := int(p.dec.InputOffset())
+	for offset < len(p.data) {
+		switch p.data[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// position returns the 1-based line and column of offset.
+func (p *jsonParser) position(offset int) (line, column int) {
+	i := sort.Search(len(p.lineStarts), func(i int) bool { return p.lineStarts[i] > offset }) - 1
+	return i + 1, offset - p.lineStarts[i] + 1
+}
+
+// syntaxError adds the position of a JSON syntax error to its message.
+func (p *jsonParser) syntaxError(err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line, column := p.position(max(int(syntaxErr.Offset)-1, 0)) // Offset is just past the error
+		return fmt.Errorf("line %d, column %d: %w", line, column, err)
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		line, _ := p.position(len(p.data))
+		return fmt.Errorf("line %d: unexpected end of JSON input", line)
+	}
+	return err
+}
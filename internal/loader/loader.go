@@ -0,0 +1,216 @@
+// Package loader reads workflow files, in YAML or JSON, into core.Workflow values. Both
+// formats are parsed into the same YAML node tree, so they are expanded, checked and
+// decoded alike: step templates a workflow imports are expanded into plain steps, and
+// keys that match no field are rejected instead of being ignored. ${env:<name>}
+// references are replaced with environment variables; since JSON strings are quoted, they
+// always interpolate as strings there, unlike unquoted YAML scalars.
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sire-run/sire/internal/core"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the syntax of a workflow or template file.
+type Format string
+
+const (
+	// FormatYAML is used for files ending in .yaml or .yml.
+	FormatYAML Format = "yaml"
+	// FormatJSON is used for files ending in .json.
+	FormatJSON Format = "json"
+)
+
+// FormatOf returns the format of the file at path, from its extension.
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".json":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported extension for %s (expected .yaml, .yml or .json)", path)
+	}
+}
+
+// Option configures how a workflow file is loaded.
+type Option func(*options)
+
+type options struct {
+	env *Env
+}
+
+// WithEnv resolves ${env:<name>} references against env, e.g. to read variables from an
+// env file or record the resolved values. By default only the process environment is used.
+func WithEnv(env *Env) Option {
+	return func(o *options) {
+		o.env = env
+	}
+}
+
+// Load reads the workflow file at path and decodes it once LoadNode has prepared it,
+// expanding matrix steps into their instances. Syntax errors, template and environment
+// errors, unknown fields and invalid matrices are reported as *Error values.
+func Load(path string, opts ...Option) (*core.Workflow, error) {
+	doc, err := LoadNode(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	workflow, err := Decode(doc)
+	if err != nil {
+		return nil, positioned(path, err)
+	}
+	if err := workflow.ExpandMatrix(); err != nil {
+		return nil, matrixErrors(path, doc, err)
+	}
+	return workflow, nil
+}
+
+// LoadNode reads the workflow file at path, expands the step templates it imports and
+// interpolates its environment variables, returning the document ready for Decode.
+func LoadNode(path string, opts ...Option) (*yaml.Node, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.env == nil {
+		o.env = NewEnv(nil)
+	}
+
+	doc, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := ExpandTemplates(path, doc, o.env); err != nil {
+		return nil, err
+	}
+	if err := Interpolate(path, doc, o.env); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// ParseFile reads and parses the file at path; see Parse.
+func ParseFile(path string) (*yaml.Node, error) {
+	// Clean the path to remove any ../ or ./ components. This does not restrict which
+	// files can be read: workflow files are trusted like the command line naming them.
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, data)
+}
+
+// Parse parses data, the content of the file at path, with the syntax matching the file's
+// extension into a YAML document node. Nodes parsed from JSON keep their lines and columns,
+// so later problems are reported at the same positions as for YAML. Syntax errors are
+// reported as an *Error.
+func Parse(path string, data []byte) (*yaml.Node, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatJSON {
+		doc, err := parseJSON(data)
+		if err != nil {
+			return nil, positioned(path, err)
+		}
+		return doc, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, positioned(path, err)
+	}
+	return &doc, nil
+}
+
+// Decode decodes a workflow document prepared by LoadNode. Unlike
+// yaml.Node.Decode, it rejects keys that match no field, e.g. a misspelled `retires:`.
+// Those and any other decode errors are reported in a *yaml.TypeError, one "line N: ..."
+// or "line N, column M: ..." message per problem. With a *yaml.TypeError, the workflow is
+// still returned with everything that did decode, so that it can be checked further.
+func Decode(doc *yaml.Node) (*core.Workflow, error) {
+	var workflow core.Workflow
+	err := decode(doc, &workflow)
+	var typeErr *yaml.TypeError
+	if err != nil && !errors.As(err, &typeErr) {
+		return nil, err
+	}
+	return &workflow, err
+}
+
+// decode decodes node into out, rejecting unknown fields.
+func decode(node *yaml.Node, out interface{}) error {
+	messages := unknownFields(node, reflectType(out))
+	err := node.Decode(out)
+	var typeErr *yaml.TypeError
+	switch {
+	case errors.As(err, &typeErr):
+		messages = append(messages, typeErr.Errors...)
+	case err != nil:
+		return err
+	}
+	if len(messages) > 0 {
+		return &yaml.TypeError{Errors: messages}
+	}
+	return nil
+}
+
+// matrixErrors converts the *core.MatrixError values joined in err into *Error values at
+// the matrix of the step each is about.
+func matrixErrors(path string, doc *yaml.Node, err error) error {
+	matrices := make(map[string]*yaml.Node)
+	for _, step := range Items(Field(doc.Content[0], "steps")) {
+		if id := Field(step, "id"); id != nil {
+			matrices[id.Value] = Field(step, "matrix")
+		}
+	}
+	split := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		split = joined.Unwrap()
+	}
+	errs := make([]error, 0, len(split))
+	for _, err := range split {
+		var matrixErr *core.MatrixError
+		if errors.As(err, &matrixErr) && matrices[matrixErr.StepID] != nil {
+			matrix := matrices[matrixErr.StepID]
+			err = &Error{File: path, Line: matrix.Line, Column: matrix.Column, Err: err}
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// errorLine matches the position in yaml.v3 error messages, e.g. "yaml: line 3: ...", and
+// in those of this package, which may add a column, e.g. "line 3, column 5: ...".
+var errorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+)(?:, column (\d+))?: (.*)$`)
+
+// positioned converts yaml.v3 errors, which name lines but not files, into *Error values
+// in file. Other errors are returned unchanged.
+func positioned(file string, err error) error {
+	messages := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+	errs := make([]error, 0, len(messages))
+	for _, message := range messages {
+		m := errorLine.FindStringSubmatch(message)
+		if m == nil {
+			return err
+		}
+		line, _ := strconv.Atoi(m[1])
+		column, _ := strconv.Atoi(m[2])
+		errs = append(errs, &Error{File: file, Line: line, Column: column, Err: errors.New(m[3])})
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,214 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const yamlWorkflow = `id: deploy
+name: Deploy
+inputs:
+  - name: replicas
+    type: integer
+    default: 2
+steps:
+  - id: build
+    tool: sire:local/build.run
+    params:
+      tags: [latest, v1]
+      verbose: true
+      ratio: 0.5
+      note: null
+  - id: ship
+    tool: sire:local/ship.run
+    retry:
+      max_attempts: 3
+    depends_on: [build]
+edges:
+  - from: build
+    to: [ship]
+`
+
+const jsonWorkflow = `{
+  "id": "deploy",
+  "name": "Deploy",
+  "inputs": [{"name": "replicas", "type": "integer", "default": 2}],
+  "steps": [
+    {
+      "id": "build",
+      "tool": "sire:local/build.run",
+      "params": {"tags": ["latest", "v1"], "verbose": true, "ratio": 0.5, "note": null}
+    },
+    {
+      "id": "ship",
+      "tool": "sire:local/ship.run",
+      "retry": {"max_attempts": 3},
+      "depends_on": ["build"]
+    }
+  ],
+  "edges": [{"from": "build", "to": ["ship"]}]
+}
+`
+
+func TestLoad_YAMLAndJSON(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"workflow.yml":  yamlWorkflow,
+		"workflow.json": jsonWorkflow,
+	})
+
+	fromYAML, err := Load(filepath.Join(dir, "workflow.yml"))
+	if err != nil {
+		t.Fatalf("unexpected error loading YAML: %v", err)
+	}
+	fromJSON, err := Load(filepath.Join(dir, "workflow.json"))
+	if err != nil {
+		t.Fatalf("unexpected error loading JSON: %v", err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Errorf("expected both formats to load the same workflow:\nYAML: %+v\nJSON: %+v", fromYAML, fromJSON)
+	}
+	if fromJSON.Inputs[0].Default != 2 {
+		t.Errorf("expected an integer default, got %#v", fromJSON.Inputs[0].Default)
+	}
+	if len(fromJSON.Edges) != 1 || fromJSON.Edges[0].To != "ship" {
+		t.Errorf("expected the list-valued edge to be expanded, got %v", fromJSON.Edges)
+	}
+}
+
+func TestLoad_UnknownFields(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		line    int // Of the retires key
+		column  int
+	}{
+		{
+			name:    "workflow.yml",
+			content: "id: wf\nsteps:\n  - id: a\n    tool: sire:local/test.run\n    retires:\n      max_attempts: 3\n    retry:\n      max_atempts: 2\nextra: true\n",
+			line:    5,
+			column:  5,
+		},
+		{
+			name: "workflow.json",
+			content: `{"id": "wf", "steps": [
+  {"id": "a", "tool": "sire:local/test.run",
+   "retires": {"max_attempts": 3},
+   "retry": {"max_atempts": 2}}],
+ "extra": true}`,
+			line:   3,
+			column: 4,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{tc.name: tc.content})
+			_, err := Load(filepath.Join(dir, tc.name))
+			if err == nil {
+				t.Fatalf("expected an error, got none")
+			}
+			for _, want := range []string{
+				fmt.Sprintf(`%s:%d:%d: unknown field "retires" (did you mean "retry"?)`, tc.name, tc.line, tc.column),
+				`unknown field "max_atempts" (did you mean "max_attempts"?)`,
+				`unknown field "extra"`,
+			} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected error %q to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"syntax.json":   "{\n  \"id\": \"wf\",\n  \"steps\": [\n    {\"id\": \"a\",}\n  ]\n}\n",
+		"trailing.json": `{"id": "wf"} {}`,
+		"type.json":     "{\n  \"id\": \"wf\",\n  \"steps\": {}\n}\n",
+		"workflow.txt":  "id: wf\n",
+	})
+
+	for file, want := range map[string]string{
+		"syntax.json":   "syntax.json:4:15: invalid character ','",
+		"trailing.json": "trailing.json:1: unexpected data after the top-level JSON value",
+		"type.json":     "type.json:3: cannot unmarshal !!map into []core.Step",
+		"workflow.txt":  "unsupported extension",
+	} {
+		_, err := Load(filepath.Join(dir, file))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", file, want, err)
+		}
+	}
+
+	_, err := Load(filepath.Join(dir, "syntax.json"))
+	var loaderErr *Error
+	if !errors.As(err, &loaderErr) || loaderErr.Line != 4 {
+		t.Errorf("expected an *Error on line 4, got %#v", err)
+	}
+}
+
+func TestParse_JSONPositions(t *testing.T) {
+	doc, err := Parse("workflow.json", []byte(jsonWorkflow))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ship := Field(doc.Content[0], "steps").Content[1]
+	if ship.Line != 11 || ship.Column != 5 {
+		t.Errorf("expected the second step at 11:5, got %d:%d", ship.Line, ship.Column)
+	}
+	if retry := Field(ship, "retry"); retry == nil || retry.Line != 14 || retry.Column != 16 {
+		t.Errorf("expected retry's value at 14:16, got %+v", retry)
+	}
+}
+
+func TestExpandTemplates_JSON(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"slack.json": `{"templates": {"notify": {
+  "params": [{"name": "channel", "required": true}],
+  "step": {"tool": "mcp:http://slack.internal/rpc#messages.send", "params": {"channel": "#${param:channel}"}}
+}}}`,
+		"workflow.json": `{"id": "wf", "import": ["slack.json"], "steps": [
+  {"id": "announce", "uses": "slack/notify", "with": {"channel": "ops"}}
+]}`,
+	})
+
+	workflow, err := Load(filepath.Join(dir, "workflow.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := workflow.Steps[0].Params["channel"]; got != "#ops" {
+		t.Errorf("expected the template to be expanded, got %v", got)
+	}
+}
+
+func TestLoad_Matrix(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"workflow.yml": "id: wf\nsteps:\n  - id: deploy\n    tool: sire:local/deploy.run\n    matrix:\n      region: [eu, us]\n      env: [prod]\n",
+		"workflow.json": `{"id": "wf", "steps": [
+  {"id": "deploy", "tool": "sire:local/deploy.run", "matrix": {"region": ["eu", "us"], "env": ["prod"]}}
+]}`,
+		"invalid.yml": "id: wf\nsteps:\n  - id: deploy\n    tool: sire:local/deploy.run\n    matrix:\n      region: []\n",
+	})
+
+	for _, file := range []string{"workflow.yml", "workflow.json"} {
+		workflow, err := Load(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", file, err)
+		}
+		var ids []string
+		for _, step := range workflow.Steps {
+			ids = append(ids, step.ID)
+		}
+		if want := []string{"deploy[region=eu,env=prod]", "deploy[region=us,env=prod]"}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("%s: expected instances %v, got %v", file, want, ids)
+		}
+	}
+
+	_, err := Load(filepath.Join(dir, "invalid.yml"))
+	var loaderErr *Error
+	if !errors.As(err, &loaderErr) || loaderErr.Line != 6 || !strings.Contains(err.Error(), "step deploy: matrix axis region has no values") {
+		t.Errorf("expected an *Error on line 6, got %v", err)
+	}
+}
@@ -0,0 +1,431 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sire-run/sire/internal/core"
+	"gopkg.in/yaml.v3"
+)
+
+// Error is a problem at a line of a workflow file or of a file it imports. Column is 1-based,
+// or zero when only the line is known.
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s:%d:%d: %v", e.File, e.Line, e.Column, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Template is a named step template defined in an imported file. Its step is any step
+// definition, without an ID, in which ${param:<name>} stands for a declared parameter.
+type Template struct {
+	Description string       `yaml:"description,omitempty"`
+	Params      []core.Input `yaml:"params,omitempty"`
+	Env         []string     `yaml:"env,omitempty"` // Environment variables the step needs
+	Step        yaml.Node    `yaml:"step"`
+}
+
+// templateFile is the content of a file imported by a workflow.
+type templateFile struct {
+	Templates map[string]*Template `yaml:"templates"`
+}
+
+// importSpec is an entry of a workflow's `import:` list: a path, or a path with the
+// namespace to use its templates under.
+type importSpec struct {
+	Path string `yaml:"path"`
+	As   string `yaml:"as,omitempty"`
+}
+
+// importedTemplate is a template along with where it was defined.
+type importedTemplate struct {
+	*Template
+	file string
+}
+
+// stepLists are the keys of the workflow lists that hold steps.
+var stepLists = []string{"steps", "on_success", "on_failure", "finally"}
+
+// paramRef matches ${param:<name>}.
+var paramRef = regexp.MustCompile(`\$\{param:([A-Za-z_][A-Za-z0-9_-]*)\}`)
+
+// ExpandTemplates expands the steps and hooks of the workflow document in doc, read from
+// path, that say `uses: <namespace>/<template>`. Each file in the workflow's `import:`
+// list, resolved relative to path, defines templates under `templates:`; its namespace is
+// the file name without extension unless set with `as:`. The `with:` values of a step are
+// checked against the template's declared params and substituted for ${param:<name>}: a
+// value that is a single reference takes the param's value as is, other strings have it
+// interpolated. The step's other keys override the template's, except for `params`, which
+// are merged key by key. Using a template fails if one of the environment variables it
+// lists under `env:` is not set in env. The template's own step is checked when its file is
+// loaded, so problems in it are reported in that file; the expanded nodes, whose remaining
+// problems come from the `with:` values, are positioned at the step using the template. The
+// `import:` key is removed, so doc decodes into a plain core.Workflow. Every problem is
+// reported as an *Error.
+func ExpandTemplates(path string, doc *yaml.Node, env *Env) error {
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	templates, err := importTemplates(path, root)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, key := range stepLists {
+		for _, step := range Items(Field(root, key)) {
+			if err := expandStep(path, step, templates, env); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// importTemplates loads the templates of the files in the workflow's `import:` list,
+// keyed by <namespace>/<name>, and removes the list from root.
+func importTemplates(path string, root *yaml.Node) (map[string]importedTemplate, error) {
+	templates := make(map[string]importedTemplate)
+	imports := Field(root, "import")
+	if imports == nil {
+		return templates, nil
+	}
+	removeField(root, "import")
+	if imports.Kind != yaml.SequenceNode {
+		return nil, &Error{File: path, Line: imports.Line, Column: imports.Column, Err: errors.New("import must be a list of files")}
+	}
+
+	var errs []error
+	namespaces := make(map[string]bool)
+	for _, entry := range imports.Content {
+		var spec importSpec
+		if entry.Kind == yaml.ScalarNode {
+			spec.Path = entry.Value
+		} else if err := entry.Decode(&spec); err != nil {
+			errs = append(errs, &Error{File: path, Line: entry.Line, Column: entry.Column, Err: fmt.Errorf("invalid import: %w", err)})
+			continue
+		}
+		if spec.Path == "" {
+			errs = append(errs, &Error{File: path, Line: entry.Line, Column: entry.Column, Err: errors.New("import must set a path")})
+			continue
+		}
+		if spec.As == "" {
+			spec.As = strings.TrimSuffix(filepath.Base(spec.Path), filepath.Ext(spec.Path))
+		}
+		if namespaces[spec.As] {
+			errs = append(errs, &Error{File: path, Line: entry.Line, Column: entry.Column, Err: fmt.Errorf("namespace %q is imported twice; set `as:` on one of the imports", spec.As)})
+			continue
+		}
+		namespaces[spec.As] = true
+
+		file := spec.Path
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		defined, err := loadTemplateFile(file)
+		var fileErr *Error
+		switch {
+		case errors.As(err, &fileErr):
+			errs = append(errs, err)
+			continue
+		case err != nil:
+			errs = append(errs, &Error{File: path, Line: entry.Line, Column: entry.Column, Err: fmt.Errorf("error importing %s: %w", spec.Path, err)})
+			continue
+		}
+		for name, template := range defined {
+			templates[spec.As+"/"+name] = importedTemplate{Template: template, file: file}
+		}
+	}
+	return templates, errors.Join(errs...)
+}
+
+// loadTemplateFile reads the templates defined in file. Problems with the templates
+// themselves, including unknown fields and values of the wrong type in their steps, are
+// reported as an *Error in file, rather than at every step that uses them.
+func loadTemplateFile(file string) (map[string]*Template, error) {
+	doc, err := ParseFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var defined templateFile
+	if err := decode(doc, &defined); err != nil {
+		return nil, positioned(file, err)
+	}
+	names := make([]string, 0, len(defined.Templates))
+	for name := range defined.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		template := defined.Templates[name]
+		if template.Step.Kind != yaml.MappingNode {
+			errs = append(errs, &Error{File: file, Line: template.Step.Line, Column: template.Step.Column, Err: fmt.Errorf("template %s must define a step", name)})
+			continue
+		}
+		if uses := Field(&template.Step, "uses"); uses != nil {
+			errs = append(errs, &Error{File: file, Line: uses.Line, Column: uses.Column, Err: fmt.Errorf("template %s: templates cannot use other templates", name)})
+			continue
+		}
+		if err := checkTemplateStep(&template.Step); err != nil {
+			errs = append(errs, positioned(file, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return defined.Templates, nil
+}
+
+// checkTemplateStep decodes a copy of a template's step with the values that reference
+// params left empty, since those are only known once the template is used.
+func checkTemplateStep(step *yaml.Node) error {
+	blank := copyNode(step)
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 1; i < len(node.Content); i += 2 {
+				walk(node.Content[i])
+			}
+		case yaml.SequenceNode:
+			for _, item := range node.Content {
+				walk(item)
+			}
+		case yaml.ScalarNode:
+			if paramRef.MatchString(node.Value) {
+				node.Tag, node.Value, node.Style = "!!null", "", 0
+			}
+		}
+	}
+	walk(blank)
+	var decoded core.Step
+	return decode(blank, &decoded)
+}
+
+// expandStep replaces a step that uses a template with the template's step.
+func expandStep(path string, step *yaml.Node, templates map[string]importedTemplate, env *Env) error {
+	uses := Field(step, "uses")
+	if uses == nil {
+		return nil
+	}
+	owner := "step"
+	if id := Field(step, "id"); id != nil {
+		owner += " " + id.Value
+	}
+	fail := func(err error) error {
+		return &Error{File: path, Line: uses.Line, Column: uses.Column, Err: fmt.Errorf("%s: %w", owner, err)}
+	}
+
+	template, ok := templates[uses.Value]
+	if !ok {
+		known := make([]string, 0, len(templates))
+		for name := range templates {
+			known = append(known, name)
+		}
+		sort.Strings(known)
+		return fail(fmt.Errorf("unknown template %q (imported: %s)", uses.Value, strings.Join(known, ", ")))
+	}
+	var unset []string
+	for _, name := range template.Env {
+		if _, ok := env.Lookup(name); !ok {
+			unset = append(unset, name)
+		}
+	}
+	if len(unset) > 0 {
+		return fail(fmt.Errorf("template %s requires environment variable %s, which is not set", uses.Value, strings.Join(unset, ", ")))
+	}
+
+	var with map[string]interface{}
+	if node := Field(step, "with"); node != nil {
+		if err := node.Decode(&with); err != nil {
+			return fail(fmt.Errorf("invalid with: %w", err))
+		}
+	}
+	values, err := resolveParams(template.Params, with)
+	if err != nil {
+		return fail(fmt.Errorf("template %s: %w", uses.Value, err))
+	}
+
+	expanded := copyNode(&template.Step)
+	if err := substitute(expanded, values, template.file, uses.Value); err != nil {
+		return err
+	}
+	position(expanded, step.Line, step.Column)
+	for i := 0; i+1 < len(step.Content); i += 2 {
+		key, value := step.Content[i], step.Content[i+1]
+		switch key.Value {
+		case "uses", "with":
+			continue
+		case "params":
+			if params := Field(expanded, "params"); params != nil && params.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+				for j := 0; j+1 < len(value.Content); j += 2 {
+					setField(params, value.Content[j], value.Content[j+1])
+				}
+				continue
+			}
+		}
+		setField(expanded, key, value)
+	}
+	*step = *expanded
+	return nil
+}
+
+// resolveParams checks the with values of a step against a template's declared params
+// and returns them with defaults applied. Optional params that are not set are nil, so
+// that references to them are known to be declared.
+func resolveParams(params []core.Input, with map[string]interface{}) (map[string]interface{}, error) {
+	values, err := core.ResolveDeclared(params, with, "param")
+	if err != nil {
+		return nil, err
+	}
+	for _, param := range params {
+		if _, ok := values[param.Name]; !ok {
+			values[param.Name] = nil
+		}
+	}
+	return values, nil
+}
+
+// substitute replaces the ${param:<name>} references in the scalar values of node, a copy
+// of the step of the named template defined in file.
+func substitute(node *yaml.Node, values map[string]interface{}, file, name string) error {
+	var errs []error
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 1; i < len(node.Content); i += 2 {
+				walk(node.Content[i])
+			}
+		case yaml.SequenceNode:
+			for _, item := range node.Content {
+				walk(item)
+			}
+		case yaml.ScalarNode:
+			if err := substituteScalar(node, values); err != nil {
+				errs = append(errs, &Error{File: file, Line: node.Line, Column: node.Column, Err: fmt.Errorf("template %s: %w", name, err)})
+			}
+		}
+	}
+	walk(node)
+	return errors.Join(errs...)
+}
+
+// substituteScalar replaces the ${param:<name>} references in a scalar node.
+func substituteScalar(node *yaml.Node, values map[string]interface{}) error {
+	matches := paramRef.FindAllStringSubmatchIndex(node.Value, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	for _, m := range matches {
+		if _, ok := values[node.Value[m[2]:m[3]]]; !ok {
+			return fmt.Errorf("references undeclared param %q", node.Value[m[2]:m[3]])
+		}
+	}
+
+	// A single whole reference keeps the value's type.
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(node.Value) {
+		var replacement yaml.Node
+		if err := replacement.Encode(values[node.Value[matches[0][2]:matches[0][3]]]); err != nil {
+			return err
+		}
+		*node = replacement
+		return nil
+	}
+	var errs []error
+	node.Value = paramRef.ReplaceAllStringFunc(node.Value, func(ref string) string {
+		name := paramRef.FindStringSubmatch(ref)[1]
+		switch value := values[name].(type) {
+		case nil:
+			return ""
+		case map[string]interface{}, []interface{}:
+			errs = append(errs, fmt.Errorf("param %q is a %T and cannot be interpolated into a string", name, value))
+			return ref
+		default:
+			return fmt.Sprint(value)
+		}
+	})
+	node.Tag, node.Style = "!!str", 0
+	return errors.Join(errs...)
+}
+
+// copyNode returns a deep copy of node.
+func copyNode(node *yaml.Node) *yaml.Node {
+	c := *node
+	c.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		c.Content[i] = copyNode(child)
+	}
+	return &c
+}
+
+// position moves node and its children to line and column.
+func position(node *yaml.Node, line, column int) {
+	node.Line, node.Column = line, column
+	for _, child := range node.Content {
+		position(child, line, column)
+	}
+}
+
+// Field returns the value of key in a mapping node, or nil.
+func Field(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setField sets key to value in a mapping node, replacing any existing value.
+func setField(node, key, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key.Value {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, key, value)
+}
+
+// removeField removes key from a mapping node.
+func removeField(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// Items returns the items of a sequence node, or nil.
+func Items(node *yaml.Node) []*yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return node.Content
+}
@@ -0,0 +1,282 @@
+package loader
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sire-run/sire/internal/core"
+	"gopkg.in/yaml.v3"
+)
+
+const notifyTemplates = `templates:
+  notify-slack:
+    description: Post a message to a Slack channel
+    params:
+      - name: channel
+        type: string
+        required: true
+      - name: text
+        default: "Workflow {{ .workflow.id }} finished"
+      - name: mentions
+        type: array
+    step:
+      tool: mcp:http://slack.internal/rpc#messages.send
+      params:
+        channel: "#${param:channel}"
+        text: ${param:text}
+        mentions: ${param:mentions}
+      retry:
+        max_attempts: 3
+`
+
+// writeFiles writes files, keyed by name, to a new directory and returns its path.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// expand parses the workflow at path, expands its templates and decodes it.
+func expand(t *testing.T, path string) (*core.Workflow, error) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("failed to parse %s: %v", path, err)
+	}
+	if err := ExpandTemplates(path, &doc, NewEnv(nil)); err != nil {
+		return nil, err
+	}
+	var workflow core.Workflow
+	if err := doc.Decode(&workflow); err != nil {
+		t.Fatalf("failed to decode the expanded workflow: %v", err)
+	}
+	return &workflow, nil
+}
+
+func TestExpandTemplates(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"templates/slack.yml": notifyTemplates,
+		"workflow.yml": `id: deploy
+import:
+  - templates/slack.yml
+  - path: templates/slack.yml
+    as: chat
+steps:
+  - id: build
+    tool: sire:local/build.run
+  - id: announce
+    uses: slack/notify-slack
+    with:
+      channel: deploys
+      mentions: [alice, bob]
+    params:
+      icon: rocket
+    retry:
+      max_attempts: 5
+    depends_on: [build]
+finally:
+  - id: notify
+    uses: chat/notify-slack
+    with:
+      channel: ops
+`,
+	})
+
+	workflow, err := expand(t, filepath.Join(dir, "workflow.yml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	announce := workflow.Steps[1]
+	if announce.ID != "announce" || announce.Tool != "mcp:http://slack.internal/rpc#messages.send" {
+		t.Errorf("expected the template's tool under the step's ID, got %+v", announce)
+	}
+	if got := announce.Params["channel"]; got != "#deploys" {
+		t.Errorf("expected the channel to be interpolated, got %v", got)
+	}
+	if got := announce.Params["text"]; got != "Workflow {{ .workflow.id }} finished" {
+		t.Errorf("expected the default text, got %v", got)
+	}
+	if got, ok := announce.Params["mentions"].([]interface{}); !ok || len(got) != 2 {
+		t.Errorf("expected the mentions list to keep its type, got %#v", announce.Params["mentions"])
+	}
+	if got := announce.Params["icon"]; got != "rocket" {
+		t.Errorf("expected the step's params to be merged in, got %v", got)
+	}
+	if announce.Retry == nil || announce.Retry.MaxAttempts != 5 {
+		t.Errorf("expected the step's retry to override the template's, got %+v", announce.Retry)
+	}
+	if len(announce.DependsOn) != 1 || announce.DependsOn[0] != "build" {
+		t.Errorf("expected depends_on to be kept, got %v", announce.DependsOn)
+	}
+
+	notify := workflow.Finally[0]
+	if notify.Params["channel"] != "#ops" || notify.Params["mentions"] != nil {
+		t.Errorf("expected the hook to be expanded with its own values, got %v", notify.Params)
+	}
+	if notify.Retry == nil || notify.Retry.MaxAttempts != 3 {
+		t.Errorf("expected the template's retry, got %+v", notify.Retry)
+	}
+}
+
+func TestExpandTemplates_PositionsAtUseSite(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"slack.yml":    notifyTemplates,
+		"workflow.yml": "id: wf\nimport: [slack.yml]\nsteps:\n  - id: announce\n    uses: slack/notify-slack\n    with: {channel: ops}\n",
+	})
+	path := filepath.Join(dir, "workflow.yml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read workflow: %v", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("failed to parse workflow: %v", err)
+	}
+	if err := ExpandTemplates(path, &doc, NewEnv(nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	step := doc.Content[0].Content[3].Content[0]
+	if tool := Field(step, "tool"); tool == nil || tool.Line != 4 {
+		t.Errorf("expected the expanded tool at the using step's line 4, got %+v", tool)
+	}
+	if Field(doc.Content[0], "import") != nil {
+		t.Errorf("expected the import key to be removed")
+	}
+}
+
+func TestExpandTemplates_Errors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"slack.yml": notifyTemplates,
+		"broken.yml": `templates:
+  bad:
+    params:
+      - name: known
+    step:
+      tool: sire:local/test.run
+      params:
+        value: ${param:unknown}
+`,
+		"workflow.yml": `id: wf
+import: [slack.yml, broken.yml]
+steps:
+  - id: missing_param
+    uses: slack/notify-slack
+  - id: wrong_type
+    uses: slack/notify-slack
+    with:
+      channel: 42
+      color: red
+  - id: typo
+    uses: slack/notify-slak
+  - id: broken
+    uses: broken/bad
+`,
+	})
+
+	_, err := expand(t, filepath.Join(dir, "workflow.yml"))
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	for _, want := range []string{
+		`workflow.yml:5:11: step missing_param: template slack/notify-slack: param "channel" is required`,
+		`workflow.yml:7:11: step wrong_type: template slack/notify-slack: param "channel" must be of type string, got int`,
+		`param "color" is not declared`,
+		`workflow.yml:12:11: step typo: unknown template "slack/notify-slak" (imported: broken/bad, slack/notify-slack)`,
+		`broken.yml:8:16: template broken/bad: references undeclared param "unknown"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error %q to contain %q", err, want)
+		}
+	}
+	var loaderErr *Error
+	if !errors.As(err, &loaderErr) || loaderErr.File == "" || loaderErr.Line == 0 {
+		t.Errorf("expected an *Error with a position, got %#v", err)
+	}
+}
+
+func TestExpandTemplates_TemplateStepErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"templates/notify.yml": `templates:
+  notify:
+    params:
+      - name: attempts
+        type: int
+    step:
+      tool: sire:local/notify.send
+      retry:
+        max_attempts: ${param:attempts}
+      retires: 3
+      timeout: [30s]
+`,
+		"wf.yml": `id: wf
+import: [templates/notify.yml]
+steps:
+  - id: notify
+    uses: notify/notify
+    with:
+      attempts: 3
+`,
+	})
+
+	_, err := expand(t, filepath.Join(dir, "wf.yml"))
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	for _, want := range []string{
+		filepath.Join("templates", "notify.yml") + `:10:7: unknown field "retires" (did you mean "retry"?)`,
+		filepath.Join("templates", "notify.yml") + ":11: cannot unmarshal !!seq into string",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error %q to contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "wf.yml") || strings.Contains(err.Error(), "max_attempts") {
+		t.Errorf("expected only the template's own problems, reported in the template file, got %q", err)
+	}
+}
+
+func TestExpandTemplates_ImportErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a/slack.yml": notifyTemplates,
+		"b/slack.yml": notifyTemplates,
+		"nested.yml":  "templates:\n  outer:\n    step:\n      uses: slack/notify-slack\n",
+		"workflow.yml": `id: wf
+import:
+  - a/slack.yml
+  - b/slack.yml
+  - missing.yml
+  - nested.yml
+steps: []
+`,
+	})
+
+	_, err := expand(t, filepath.Join(dir, "workflow.yml"))
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	for _, want := range []string{
+		`workflow.yml:4:5: namespace "slack" is imported twice`,
+		`workflow.yml:5:5: error importing missing.yml`,
+		`nested.yml:4:13: template outer: templates cannot use other templates`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error %q to contain %q", err, want)
+		}
+	}
+}
@@ -13,9 +13,9 @@ type MCPService struct {
 }
 
 // NewService creates a new MCPService.
-func NewService() *MCPService {
+func NewService(opts ...service.ToolProviderOption) *MCPService {
 	return &MCPService{
-		toolProvider: service.NewToolProvider(),
+		toolProvider: service.NewToolProvider(opts...),
 	}
 }
 
@@ -32,6 +32,10 @@ func (s *MCPService) Initialize(r *http.Request, args *struct{}, reply *map[stri
 					"name":        "sire/createWorkflow",
 					"description": "Create a new Sire workflow.",
 				},
+				{
+					"name":        "sire/signalExecution",
+					"description": "Send a signal to a step of a workflow execution waiting for one.",
+				},
 			},
 		},
 	}
@@ -58,6 +62,14 @@ func (s *MCPService) ToolExecute(r *http.Request, args *struct {
 			return err
 		}
 		*reply = wf
+	case "sire/signalExecution":
+		if len(args.Params) != 1 {
+			return fmt.Errorf("sire/signalExecution takes a single params object")
+		}
+		if err := s.toolProvider.SignalExecution(args.Params[0]); err != nil {
+			return err
+		}
+		*reply = map[string]interface{}{"signalled": true}
 	default:
 		return fmt.Errorf("method not found")
 	}
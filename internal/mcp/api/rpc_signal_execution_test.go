@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/sire-run/sire/internal/core"
+	"github.com/sire-run/sire/internal/mcp/service"
+	"github.com/sire-run/sire/internal/storage"
+)
+
+// signalResponse is a JSON-RPC 2.0 response to a sire/signalExecution call.
+type signalResponse struct {
+	Result map[string]interface{} `json:"result"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// callSignalExecution sends a sire/signalExecution call with params to server.
+func callSignalExecution(t *testing.T, server *httptest.Server, params string) signalResponse {
+	t.Helper()
+	reqBody := `{"jsonrpc":"2.0","method":"mcp.ToolExecute","params":[{"name":"sire/signalExecution","params":[` + params + `]}],"id":1}`
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL, bytes.NewBufferString(reqBody))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Fatalf("failed to close response body: %v", err)
+		}
+	}()
+
+	var rpcResp signalResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return rpcResp
+}
+
+func TestMCPService_ToolExecute_SignalExecution(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "sire.db")
+	store, err := storage.NewBoltDBStore(dbPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	execution := &core.Execution{
+		ID:     "exec-1",
+		Status: core.ExecutionStatusWaiting,
+		Workflow: &core.Workflow{
+			ID:    "approval",
+			Steps: []core.Step{{ID: "approve", Signal: &core.SignalSpec{}}},
+		},
+		StepStates: map[string]*core.StepState{"approve": {Status: core.StepStatusWaiting}},
+	}
+	if err := store.SaveExecution(execution); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The server opens the database per call, so it must not be held here.
+	if err := store.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	if err := s.RegisterService(NewService(service.WithDatabase(dbPath)), "mcp"); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name   string
+		params string
+		want   string // Expected error, empty for success
+	}{
+		{"bad data", `{"executionId":"exec-1","stepId":"approve","data":"yes"}`, "'data' must be an object"},
+		{"unknown execution", `{"executionId":"exec-missing","stepId":"approve"}`, "failed to load execution exec-missing"},
+		{"success", `{"executionId":"exec-1","stepId":"approve","data":{"approved":true}}`, ""},
+		{"already signalled", `{"executionId":"exec-1","stepId":"approve"}`, "step approve has already been signalled"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rpcResp := callSignalExecution(t, server, tc.params)
+			switch {
+			case tc.want == "" && rpcResp.Error != nil:
+				t.Errorf("unexpected error: %s", rpcResp.Error.Message)
+			case tc.want == "" && rpcResp.Result["signalled"] != true:
+				t.Errorf("expected the signal to be acknowledged, got %v", rpcResp.Result)
+			case tc.want != "" && (rpcResp.Error == nil || !strings.Contains(rpcResp.Error.Message, tc.want)):
+				t.Errorf("expected an error containing %q, got %+v", tc.want, rpcResp)
+			}
+		})
+	}
+
+	store, err = storage.NewBoltDBStore(dbPath)
+	if err != nil {
+		t.Fatalf("expected the server to release the database, got %v", err)
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}()
+	payload, ok, err := store.LoadSignal("exec-1", "approve")
+	if err != nil || !ok || payload["approved"] != true {
+		t.Errorf("expected the signal payload to be stored, got %v (found: %v, error: %v)", payload, ok, err)
+	}
+}
@@ -37,6 +37,10 @@ type JSONRPCError struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// defaultTimeout bounds a remote call when the caller's context has no deadline,
+// such as a step without a `timeout:`.
+const defaultTimeout = 30 * time.Second
+
 // RemoteDispatcher dispatches tool executions to remote MCP servers.
 type RemoteDispatcher struct {
 	client *http.Client
@@ -45,9 +49,7 @@ type RemoteDispatcher struct {
 // NewRemoteDispatcher creates a new RemoteDispatcher.
 func NewRemoteDispatcher() *RemoteDispatcher {
 	return &RemoteDispatcher{
-		client: &http.Client{
-			Timeout: 30 * time.Second, // Default timeout
-		},
+		client: &http.Client{},
 	}
 }
 
@@ -95,6 +97,13 @@ func (d *RemoteDispatcher) Dispatch(ctx context.Context, toolURI string, params
 		return nil, fmt.Errorf("failed to marshal JSON-RPC request: %w", err)
 	}
 
+	// Step timeouts arrive through ctx; only fall back to the default when there is none.
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", httpURL, bytes.NewBuffer(reqBytes))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
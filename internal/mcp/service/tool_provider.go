@@ -5,14 +5,34 @@ import (
 
 	"github.com/sire-run/sire/internal/core"
 	"github.com/sire-run/sire/internal/mcp/inprocess"
+	"github.com/sire-run/sire/internal/storage"
 )
 
 // ToolProvider provides the implementation for the MCP tools.
-type ToolProvider struct{}
+type ToolProvider struct {
+	dbPath string // BoltDB file of the executions to act on, empty if the server has none
+}
+
+// ToolProviderOption configures optional ToolProvider behavior.
+type ToolProviderOption func(*ToolProvider)
+
+// WithDatabase sets the BoltDB file of the executions the tools act on. The file is opened
+// for each call and closed after it, since BoltDB lets a single process hold it: a server
+// keeping it open would lock sire run and sire execution out of it. A call made while
+// another process holds the file fails once the open times out.
+func WithDatabase(path string) ToolProviderOption {
+	return func(p *ToolProvider) {
+		p.dbPath = path
+	}
+}
 
 // NewToolProvider creates a new ToolProvider.
-func NewToolProvider() *ToolProvider {
-	return &ToolProvider{}
+func NewToolProvider(opts ...ToolProviderOption) *ToolProvider {
+	p := &ToolProvider{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // ListTools lists the available Sire tools.
@@ -61,3 +81,33 @@ func (p *ToolProvider) CreateWorkflow(inputSteps []map[string]interface{}) (*cor
 		Edges: edges,
 	}, nil
 }
+
+// SignalExecution sends a signal to a step of an execution waiting for one.
+// The input map should contain "executionId" and "stepId", and optionally "data",
+// the payload that becomes the step output.
+func (p *ToolProvider) SignalExecution(input map[string]interface{}) error {
+	if p.dbPath == "" {
+		return fmt.Errorf("signalling executions requires a server started with a database")
+	}
+	executionID, ok := input["executionId"].(string)
+	if !ok {
+		return fmt.Errorf("'executionId' is required and must be a string")
+	}
+	stepID, ok := input["stepId"].(string)
+	if !ok {
+		return fmt.Errorf("'stepId' is required and must be a string")
+	}
+	var data map[string]interface{} // data is optional
+	if value, ok := input["data"]; ok && value != nil {
+		if data, ok = value.(map[string]interface{}); !ok {
+			return fmt.Errorf("'data' must be an object")
+		}
+	}
+
+	store, err := storage.NewBoltDBStore(p.dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func() { _ = store.Close() }()
+	return core.NewEngine(nil, store).Signal(executionID, stepID, data)
+}
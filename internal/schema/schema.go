@@ -0,0 +1,295 @@
+// Package schema generates the JSON Schema of workflow files from the core.Workflow types,
+// for editor autocompletion and for checking workflow files in CI.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/sire-run/sire/internal/core"
+)
+
+// Schema is the subset of a JSON Schema (draft 2020-12) used for workflow files.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             int                `json:"minItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Values with ${env:NAME} references are only known once interpolated, so the patterns accept
+// any value that contains one.
+const (
+	// envReference matches values with an environment variable reference.
+	envReference = `\$\{env:`
+	// wholeEnvReference matches values that are a single environment variable reference,
+	// the only ones that take the type of the variable's value when interpolated.
+	wholeEnvReference = `^\$\{env:[A-Za-z_][A-Za-z0-9_]*(:-[^}]*)?\}$`
+	// durationPattern matches Go durations such as "30s" or "1h30m".
+	durationPattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$|` + envReference
+	// toolPattern matches the tool URIs the CLI can dispatch.
+	toolPattern = `^(sire:local/[^./]+\..+|mcp:https?://[^#]+#.+)$|` + envReference
+)
+
+// Workflow returns the JSON Schema of workflow files. Every struct reachable from
+// core.Workflow becomes a definition, with its fields named by their YAML tags.
+func Workflow() *Schema {
+	g := &generator{defs: make(map[string]*Schema)}
+	root := g.object(reflect.TypeOf(core.Workflow{}))
+	root.Schema = "https://json-schema.org/draft/2020-12/schema"
+	root.Title = "Sire workflow"
+	root.Defs = g.defs
+	return root
+}
+
+// Generate returns the JSON Schema of workflow files as indented JSON.
+func Generate() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false) // Keep the <placeholders> in descriptions readable
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(Workflow()); err != nil {
+		return nil, fmt.Errorf("failed to marshal workflow schema: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// generator builds schemas from Go types, collecting struct definitions in defs.
+type generator struct {
+	defs map[string]*Schema
+}
+
+// object returns the schema of a struct type, described by types and fields.
+func (g *generator) object(t reflect.Type) *Schema {
+	closed := false
+	s := &Schema{
+		Description:          types[t.Name()],
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		Required:             required[t.Name()],
+		AdditionalProperties: &closed,
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		property := fields[t.Name()+"."+name]
+		if property.Type == "" && property.Ref == "" && property.OneOf == nil {
+			generated := g.of(field.Type)
+			property.Type, property.Ref, property.Items, property.Enum = generated.Type, generated.Ref, generated.Items, valueOr(property.Enum, generated.Enum)
+		}
+		s.Properties[name] = acceptEnvReference(&property)
+	}
+	for name, property := range loaderFields[t.Name()] {
+		s.Properties[name] = property
+	}
+	return s
+}
+
+// of returns the schema of a field type. Structs are referenced as definitions.
+func (g *generator) of(t reflect.Type) *Schema {
+	if t == reflect.TypeOf(core.EdgeList{}) {
+		return &Schema{Type: "array", Items: g.ref(reflect.TypeOf(core.Edge{}))}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.of(t.Elem())
+	case reflect.Struct:
+		return g.ref(t)
+	case reflect.Slice:
+		return &Schema{Type: "array", Items: g.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.String:
+		return &Schema{Type: "string", Enum: enums[t]}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	default:
+		return &Schema{} // Any value
+	}
+}
+
+// ref returns a reference to the definition of a struct type, generating it on first use.
+func (g *generator) ref(t reflect.Type) *Schema {
+	if _, ok := g.defs[t.Name()]; !ok {
+		g.defs[t.Name()] = nil // Guards against recursive types
+		g.defs[t.Name()] = g.object(t)
+	}
+	return &Schema{Ref: "#/$defs/" + t.Name()}
+}
+
+// acceptEnvReference returns the schema of a field that also accepts the ${env:NAME}
+// references which interpolate to one of its values. The fields with a pattern already
+// accept them; those with another type than string, or a fixed set of values, are given
+// the reference as an alternative.
+func acceptEnvReference(s *Schema) *Schema {
+	var reference *Schema
+	switch {
+	case s.Type == "integer" || s.Type == "number" || s.Type == "boolean":
+		reference = &Schema{Type: "string", Pattern: wholeEnvReference}
+	case len(s.Enum) > 0:
+		reference = &Schema{Type: "string", Pattern: envReference}
+	default:
+		return s
+	}
+	value := *s
+	value.Description = ""
+	return &Schema{Description: s.Description, AnyOf: []*Schema{&value, reference}}
+}
+
+// valueOr returns v, or fallback if v is empty.
+func valueOr(v, fallback []string) []string {
+	if len(v) > 0 {
+		return v
+	}
+	return fallback
+}
+
+// enums lists the values of the string types with a fixed set of values.
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(core.SkipPolicy("")):    {string(core.SkipPolicyPropagate), string(core.SkipPolicyIgnore)},
+	reflect.TypeOf(core.OnErrorPolicy("")): {string(core.OnErrorFail), string(core.OnErrorContinue), string(core.OnErrorFallback)},
+	reflect.TypeOf(core.WiringMode("")):    {string(core.WiringMerge), string(core.WiringStrict)},
+	reflect.TypeOf(core.InputType("")): {
+		string(core.InputTypeString), string(core.InputTypeNumber), string(core.InputTypeInteger),
+		string(core.InputTypeBoolean), string(core.InputTypeObject), string(core.InputTypeArray),
+	},
+}
+
+// required lists the required fields of each struct type.
+var required = map[string][]string{
+	"Workflow": {"id", "steps"},
+	"Step":     {"id"},
+	"ToolCall": {"tool"},
+	"Input":    {"name"},
+	"Output":   {"name", "value"},
+	"Edge":     {"from", "to"},
+}
+
+// types describes the struct types.
+var types = map[string]string{
+	"Workflow":    "A workflow: steps connected by edges, run by the Sire engine.",
+	"Step":        "A unit of work. A step dispatches its tool, unless it runs a sub-workflow, waits for a signal, or sleeps.",
+	"ToolCall":    "An additional tool invocation attached to a step. Its params are resolved like step params.",
+	"RetryPolicy": "How a failed step is retried.",
+	"SignalSpec":  "Makes a step wait for an external signal, e.g. a human approval.",
+	"Input":       "A declared workflow input, checked and defaulted before a run starts.",
+	"Output":      "A workflow output.",
+	"Edge":        "A dependency between steps. Either end may be a list of step IDs, which stands for every combination.",
+}
+
+// loaderFields describes the keys that the workflow loader handles before a file is decoded
+// into the core types, keyed by the type they appear in.
+var loaderFields = map[string]map[string]*Schema{
+	"Workflow": {
+		"import": {
+			Description: "Files defining step templates, relative to the workflow file. Their templates are used as <namespace>/<name>, where the namespace is the file name without extension unless set with as.",
+			Type:        "array",
+			Items: &Schema{OneOf: []*Schema{
+				{Type: "string"},
+				{
+					Type:       "object",
+					Properties: map[string]*Schema{"path": {Type: "string"}, "as": {Type: "string"}},
+					Required:   []string{"path"},
+				},
+			}},
+		},
+	},
+	"Step": {
+		"uses": {Description: "Step template to expand the step from, as <namespace>/<name>. The step's own keys override the template's; its params are merged with the template's.", Type: "string"},
+		"with": {Description: "Values of the template's declared params, substituted for ${param:<name>}.", Type: "object"},
+	},
+}
+
+// stepIDs accepts a step ID or a list of them, as edges do.
+var stepIDs = []*Schema{{Type: "string"}, {Type: "array", Items: &Schema{Type: "string"}, MinItems: 1}}
+
+var (
+	zero = 0.0
+	one  = 1.0
+)
+
+// fields describes the fields of the struct types, keyed by type name and YAML name. The
+// generator derives each field's type from Go; an entry's Type, Ref or OneOf replaces it.
+var fields = map[string]Schema{
+	"Workflow.id":         {Description: "Unique workflow ID, used to register and reference the workflow."},
+	"Workflow.name":       {Description: "Human-readable name."},
+	"Workflow.inputs":     {Description: "Declared inputs, available to templates as {{ .inputs.<name> }}."},
+	"Workflow.outputs":    {Description: "Outputs computed from the steps once the execution completes."},
+	"Workflow.on_success": {Description: "Hook steps run one after another when the execution completed."},
+	"Workflow.on_failure": {Description: "Hook steps run one after another when the execution failed."},
+	"Workflow.finally":    {Description: "Hook steps run after on_success or on_failure, whatever the outcome."},
+	"Workflow.retry":      {Description: "Default retry policy for steps that do not declare their own."},
+	"Workflow.timeout":    {Description: "Bounds the whole execution, as a Go duration such as \"1h\".", Pattern: durationPattern},
+	"Workflow.wiring":     {Description: "Which data a step receives: \"merge\" passes the inputs, parent outputs and params merged into one map; \"strict\" passes its params only."},
+	"Workflow.steps":      {Description: "The steps of the workflow."},
+	"Workflow.edges":      {Description: "Dependencies between steps. A step runs once every step it depends on has settled."},
+
+	"Step.id":          {Description: "Unique step ID, referenced by edges and templates as .steps.<id>."},
+	"Step.tool":        {Description: "URI of the tool to dispatch: sire:local/<service>.<method> for built-in tools, or mcp:<http URL>#<method> for a remote MCP server.", Pattern: toolPattern},
+	"Step.params":      {Description: "Tool parameters. String values may be Go templates over .inputs, .workflow, .execution and .steps.<id>.status|output|error; in foreach steps also .item and .index, and in matrix steps .matrix.<axis>. A value that is a single template keeps the type of its result."},
+	"Step.retry":       {Description: "Retry policy for the step, overriding the workflow's."},
+	"Step.depends_on":  {Description: "Steps that must settle before this one runs, as an alternative to edges."},
+	"Step.when":        {Description: "expr-lang condition over inputs and steps; the step is skipped when it is false, e.g. steps.check.output.ok."},
+	"Step.skip_policy": {Description: "How the step reacts to a skipped dependency: \"propagate\" skips it too, \"ignore\" treats it as satisfied."},
+	"Step.foreach":     {Description: "expr-lang expression yielding a list; the tool is dispatched once per item."},
+	"Step.concurrency": {Description: "Maximum foreach items in flight; 0 means unlimited.", Minimum: &zero},
+	"Step.workflow":    {Description: "Runs another workflow, by registered ID or file path, as a child execution, instead of dispatching a tool; cannot be combined with tool or foreach. Params become its inputs."},
+	"Step.compensate":  {Description: "Undoes the step's work if the execution fails after the step completed."},
+	"Step.on_error":    {Description: "What a failure does once retries are exhausted: \"fail\" the execution, \"continue\" past it, or dispatch the \"fallback\" tool."},
+	"Step.fallback":    {Description: "Tool dispatched when the step fails and on_error is \"fallback\"; its output replaces the step's."},
+	"Step.signal":      {Description: "Waits for an external signal, sent with `sire execution signal`, instead of dispatching a tool. The signal's payload becomes the output."},
+	"Step.sleep":       {Description: "Pauses the execution for a Go duration such as \"24h\" instead of dispatching a tool.", Pattern: durationPattern},
+	"Step.wait_until":  {Description: "Pauses the execution until an RFC 3339 timestamp, which may be a template, instead of dispatching a tool."},
+	"Step.matrix":      {Description: "Axes to fan the step out over, mapping each axis name to a list of values. The step runs once per combination, as deploy[region=eu,env=prod]; .steps.<id> then combines the instances' status, and their outputs and errors keyed by combination.", Type: "object"},
+	"Step.timeout":     {Description: "Bounds a single attempt of the step, as a Go duration such as \"30s\".", Pattern: durationPattern},
+
+	"SignalSpec.timeout": {Description: "How long to wait for the signal, as a Go duration; empty waits indefinitely.", Pattern: durationPattern},
+
+	"ToolCall.tool":   {Description: "URI of the tool to dispatch.", Pattern: toolPattern},
+	"ToolCall.params": {Description: "Tool parameters, which may be Go templates like step params."},
+
+	"RetryPolicy.max_attempts":         {Description: "Total number of attempts, including the first.", Minimum: &zero},
+	"RetryPolicy.backoff":              {Description: "How the interval grows between attempts.", Enum: []string{core.BackoffFixed, core.BackoffLinear, core.BackoffExponential}},
+	"RetryPolicy.initial_interval":     {Description: "Interval before the first retry, as a Go duration; defaults to 5s for fixed backoff, 1s otherwise.", Pattern: durationPattern},
+	"RetryPolicy.multiplier":           {Description: "Growth factor for exponential backoff; defaults to 2.", Minimum: &one},
+	"RetryPolicy.max_interval":         {Description: "Cap on the computed interval, as a Go duration.", Pattern: durationPattern},
+	"RetryPolicy.jitter":               {Description: "Fraction of the interval randomly taken off.", Minimum: &zero, Maximum: &one},
+	"RetryPolicy.retryable_errors":     {Description: "Only errors whose code or message matches one of these patterns are retried."},
+	"RetryPolicy.non_retryable_errors": {Description: "Errors whose code or message matches one of these patterns are never retried."},
+
+	"Input.name":        {Description: "Input name."},
+	"Input.type":        {Description: "Expected type; empty accepts any value."},
+	"Input.required":    {Description: "Whether a run must provide the input."},
+	"Input.default":     {Description: "Value used when a run does not provide the input."},
+	"Input.description": {Description: "Human-readable description."},
+
+	"Output.name":        {Description: "Output name."},
+	"Output.value":       {Description: "expr-lang expression over inputs and steps, e.g. steps.transform.output.result."},
+	"Output.description": {Description: "Human-readable description."},
+
+	"Edge.from": {Description: "The step, or steps, that must settle first.", OneOf: stepIDs},
+	"Edge.to":   {Description: "The step, or steps, that depend on from.", OneOf: stepIDs},
+}
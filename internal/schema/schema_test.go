@@ -0,0 +1,116 @@
+package schema
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the published schema with the generated one")
+
+// goldenPath is the published schema, which editors and CI use without the sire binary.
+var goldenPath = filepath.Join("..", "..", "docs", "workflow.schema.json")
+
+func TestGenerate_MatchesPublishedSchema(t *testing.T) {
+	generated, err := Generate()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *update {
+		if err := os.WriteFile(goldenPath, generated, 0o600); err != nil {
+			t.Fatalf("failed to update %s: %v", goldenPath, err)
+		}
+	}
+	published, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", goldenPath, err)
+	}
+	if !bytes.Equal(generated, published) {
+		t.Errorf("%s is out of date; run `go test ./internal/schema -update`", goldenPath)
+	}
+}
+
+func TestWorkflow_DescribesEveryField(t *testing.T) {
+	s := Workflow()
+	check := func(name string, def *Schema) {
+		if def.Description == "" {
+			t.Errorf("%s has no description; add it to types", name)
+		}
+		for property, field := range def.Properties {
+			if field.Description == "" {
+				t.Errorf("%s.%s has no description; add it to fields", name, property)
+			}
+			if field.Type == "" && field.Ref == "" && field.OneOf == nil && field.AnyOf == nil && property != "default" {
+				t.Errorf("%s.%s has no type", name, property)
+			}
+		}
+	}
+	check("Workflow", s)
+	for name, def := range s.Defs {
+		check(name, def)
+	}
+}
+
+func TestWorkflow_Structure(t *testing.T) {
+	s := Workflow()
+	for _, def := range []string{"Step", "Edge", "RetryPolicy", "ToolCall", "SignalSpec", "Input", "Output"} {
+		if s.Defs[def] == nil {
+			t.Errorf("expected a definition for %s", def)
+		}
+	}
+	if got := s.Properties["steps"].Items.Ref; got != "#/$defs/Step" {
+		t.Errorf("expected steps to reference Step, got %q", got)
+	}
+	if got := s.Properties["edges"].Items.Ref; got != "#/$defs/Edge" {
+		t.Errorf("expected edges to reference Edge, got %q", got)
+	}
+	step := s.Defs["Step"]
+	for _, field := range []string{"wait_until", "depends_on", "signal", "on_error"} {
+		if step.Properties[field] == nil {
+			t.Errorf("expected Step to have %s", field)
+		}
+	}
+	if got := step.Properties["on_error"].AnyOf[0].Enum; len(got) != 3 {
+		t.Errorf("expected on_error to list its values, got %v", got)
+	}
+	if got := step.Properties["retry"].Ref; got != "#/$defs/RetryPolicy" {
+		t.Errorf("expected retry to reference RetryPolicy, got %q", got)
+	}
+	if got := s.Defs["RetryPolicy"].Properties["backoff"].AnyOf[0].Enum; len(got) != 3 {
+		t.Errorf("expected backoff to list its values, got %v", got)
+	}
+}
+
+func TestWorkflow_Patterns(t *testing.T) {
+	s := Workflow()
+	step, retry := s.Defs["Step"], s.Defs["RetryPolicy"]
+	for _, tc := range []struct {
+		field *Schema
+		value string
+		want  bool
+	}{
+		{step.Properties["tool"], "sire:local/http.get", true},
+		{step.Properties["tool"], "mcp:https://tools.example.com/rpc#files.read", true},
+		{step.Properties["tool"], "mcp:${env:TOOLS_URL}#files.read", true},
+		{step.Properties["tool"], "http.get", false},
+		{step.Properties["timeout"], "1h30m", true},
+		{step.Properties["timeout"], "${env:STEP_TIMEOUT}", true},
+		{step.Properties["timeout"], "soon", false},
+		// Other fields take a reference as a string alternative to their own type.
+		{step.Properties["concurrency"].AnyOf[1], "${env:WORKERS}", true},
+		{step.Properties["concurrency"].AnyOf[1], "${env:WORKERS:-4}", true},
+		{step.Properties["concurrency"].AnyOf[1], "${env:WORKERS}0", false},
+		{retry.Properties["backoff"].AnyOf[1], "${env:BACKOFF}", true},
+	} {
+		pattern := regexp.MustCompile(tc.field.Pattern)
+		if got := pattern.MatchString(tc.value); got != tc.want {
+			t.Errorf("pattern %s matching %q = %v, want %v", tc.field.Pattern, tc.value, got, tc.want)
+		}
+	}
+	if got := step.Properties["concurrency"].AnyOf[0].Type; got != "integer" {
+		t.Errorf("expected concurrency to remain an integer alternative, got %q", got)
+	}
+}
@@ -12,13 +12,17 @@ import (
 // Bucket names for BoltDB
 var (
 	executionBucket = []byte("executions")
+	signalBucket    = []byte("signals")
 )
 
-// Store defines the interface for storing and retrieving workflow executions.
+// Store defines the interface for storing and retrieving workflow executions. As for
+// core.Store, SaveExecution must not overwrite a cancelled execution with one that is not.
 type Store interface {
 	SaveExecution(execution *core.Execution) error
 	LoadExecution(id string) (*core.Execution, error)
 	ListPendingExecutions() ([]*core.Execution, error)
+	SaveSignal(executionID, stepID string, payload map[string]interface{}) error
+	LoadSignal(executionID, stepID string) (map[string]interface{}, bool, error)
 	// Add other necessary methods like DeleteExecution, etc.
 }
 
@@ -27,17 +31,24 @@ type BoltDBStore struct {
 	db *bolt.DB
 }
 
+// openTimeout bounds how long NewBoltDBStore waits for the file lock held by another process.
+const openTimeout = 5 * time.Second
+
 // NewBoltDBStore creates a new BoltDBStore.
 func NewBoltDBStore(dbPath string) (*BoltDBStore, error) {
-	db, err := bolt.Open(dbPath, 0o600, nil)
+	db, err := bolt.Open(dbPath, 0o600, &bolt.Options{Timeout: openTimeout})
 	if err != nil {
 		return nil, fmt.Errorf("failed to open BoltDB: %w", err)
 	}
 
 	// Create buckets if they don't exist
 	err = db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(executionBucket)
-		return err
+		for _, bucket := range [][]byte{executionBucket, signalBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create BoltDB buckets: %w", err)
@@ -51,13 +62,26 @@ func (s *BoltDBStore) Close() error {
 	return s.db.Close()
 }
 
-// SaveExecution saves a workflow execution to BoltDB.
+// SaveExecution saves a workflow execution to BoltDB. An execution stored as cancelled is
+// only overwritten by one that is cancelled too; otherwise an error wrapping
+// core.ErrExecutionCancelled is returned.
 func (s *BoltDBStore) SaveExecution(execution *core.Execution) error {
 	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(executionBucket)
 		if b == nil {
 			return fmt.Errorf("bucket %s not found", executionBucket)
 		}
+		if stored := b.Get([]byte(execution.ID)); stored != nil && execution.Status != core.ExecutionStatusCancelled {
+			var current struct {
+				Status core.ExecutionStatus `json:"status"`
+			}
+			if err := json.Unmarshal(stored, &current); err != nil {
+				return fmt.Errorf("failed to unmarshal execution from DB: %w", err)
+			}
+			if current.Status == core.ExecutionStatusCancelled {
+				return fmt.Errorf("execution %s: %w", execution.ID, core.ErrExecutionCancelled)
+			}
+		}
 
 		// Update timestamps
 		now := time.Now()
@@ -109,8 +133,9 @@ func (s *BoltDBStore) ListPendingExecutions() ([]*core.Execution, error) {
 			if err := json.Unmarshal(v, &execution); err != nil {
 				return fmt.Errorf("failed to unmarshal execution from DB: %w", err)
 			}
-			// Assuming "running" and "retrying" are pending states
-			if execution.Status == "running" || execution.Status == "retrying" {
+			// Running, retrying, waiting, compensating and finalizing executions still have work to do
+			switch execution.Status {
+			case core.ExecutionStatusRunning, core.ExecutionStatusRetrying, core.ExecutionStatusWaiting, core.ExecutionStatusCompensating, core.ExecutionStatusFinalizing:
 				pendingExecutions = append(pendingExecutions, &execution)
 			}
 		}
@@ -121,3 +146,47 @@ func (s *BoltDBStore) ListPendingExecutions() ([]*core.Execution, error) {
 	}
 	return pendingExecutions, nil
 }
+
+// signalKey is the key of the signal sent to a step of an execution.
+func signalKey(executionID, stepID string) []byte {
+	return []byte(executionID + "/" + stepID)
+}
+
+// SaveSignal stores the payload of a signal sent to a step of an execution. Signals are kept
+// apart from executions, so delivering one never races with the engine saving the execution.
+func (s *BoltDBStore) SaveSignal(executionID, stepID string, payload map[string]interface{}) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(signalBucket)
+		if b == nil {
+			return fmt.Errorf("bucket %s not found", signalBucket)
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal signal: %w", err)
+		}
+		return b.Put(signalKey(executionID, stepID), data)
+	})
+}
+
+// LoadSignal loads the payload of the signal sent to a step of an execution. It reports
+// false if no signal has been sent yet.
+func (s *BoltDBStore) LoadSignal(executionID, stepID string) (map[string]interface{}, bool, error) {
+	var payload map[string]interface{}
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(signalBucket)
+		if b == nil {
+			return fmt.Errorf("bucket %s not found", signalBucket)
+		}
+		data := b.Get(signalKey(executionID, stepID))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &payload)
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load signal for step %s of execution %s: %w", stepID, executionID, err)
+	}
+	return payload, found, nil
+}
@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -137,6 +139,9 @@ func TestBoltDBStore_ListPendingExecutions(t *testing.T) {
 	exec2 := &core.Execution{ID: "exec-2", WorkflowID: "wf-b", Status: core.ExecutionStatusCompleted}
 	exec3 := &core.Execution{ID: "exec-3", WorkflowID: "wf-c", Status: core.ExecutionStatusRetrying}
 	exec4 := &core.Execution{ID: "exec-4", WorkflowID: "wf-d", Status: core.ExecutionStatusFailed}
+	exec5 := &core.Execution{ID: "exec-5", WorkflowID: "wf-e", Status: core.ExecutionStatusCompensating}
+	exec6 := &core.Execution{ID: "exec-6", WorkflowID: "wf-f", Status: core.ExecutionStatusFinalizing}
+	exec7 := &core.Execution{ID: "exec-7", WorkflowID: "wf-g", Status: core.ExecutionStatusWaiting}
 
 	if err := store.SaveExecution(exec1); err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -150,6 +155,15 @@ func TestBoltDBStore_ListPendingExecutions(t *testing.T) {
 	if err := store.SaveExecution(exec4); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if err := store.SaveExecution(exec5); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.SaveExecution(exec6); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.SaveExecution(exec7); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	// List pending executions
 	pending, err := store.ListPendingExecutions()
@@ -157,9 +171,9 @@ func TestBoltDBStore_ListPendingExecutions(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	// Expect exec1 and exec3 to be pending
-	if len(pending) != 2 {
-		t.Errorf("expected %d pending executions, got %d", 2, len(pending))
+	// Expect exec1, exec3, exec5, exec6 and exec7 to be pending
+	if len(pending) != 5 {
+		t.Errorf("expected %d pending executions, got %d", 5, len(pending))
 	}
 	var pendingIDs []string
 	for _, e := range pending {
@@ -171,6 +185,46 @@ func TestBoltDBStore_ListPendingExecutions(t *testing.T) {
 	if !contains(pendingIDs, "exec-3") {
 		t.Errorf("expected pending IDs to contain %q", "exec-3")
 	}
+	if !contains(pendingIDs, "exec-5") {
+		t.Errorf("expected pending IDs to contain %q", "exec-5")
+	}
+	if !contains(pendingIDs, "exec-6") {
+		t.Errorf("expected pending IDs to contain %q", "exec-6")
+	}
+	if !contains(pendingIDs, "exec-7") {
+		t.Errorf("expected pending IDs to contain %q", "exec-7")
+	}
+}
+
+func TestBoltDBStore_SaveAndLoadSignal(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "signals.db")
+	store, err := NewBoltDBStore(dbPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			t.Errorf("failed to close store: %v", err)
+		}
+	}()
+
+	if _, ok, err := store.LoadSignal("exec-1", "approve"); err != nil || ok {
+		t.Fatalf("expected no signal before one is sent, got ok=%v err=%v", ok, err)
+	}
+	if err := store.SaveSignal("exec-1", "approve", map[string]interface{}{"approved": true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	payload, ok, err := store.LoadSignal("exec-1", "approve")
+	if err != nil || !ok {
+		t.Fatalf("expected the signal to be found, got ok=%v err=%v", ok, err)
+	}
+	if payload["approved"] != true {
+		t.Errorf("expected payload %v, got %v", map[string]interface{}{"approved": true}, payload)
+	}
+	if _, ok, _ := store.LoadSignal("exec-2", "approve"); ok {
+		t.Errorf("expected signals to be kept per execution")
+	}
 }
 
 func TestBoltDBStore_OpenAndClose(t *testing.T) {
@@ -219,3 +273,34 @@ func TestBoltDBStore_OpenAndClose(t *testing.T) {
 		}
 	}()
 }
+
+func TestBoltDBStore_SaveExecution_KeepsCancellation(t *testing.T) {
+	store, err := NewBoltDBStore(filepath.Join(t.TempDir(), "sire.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}()
+
+	if err := store.SaveExecution(&core.Execution{ID: "exec-1", Status: core.ExecutionStatusCancelled}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A run that has not noticed the cancellation must not overwrite it.
+	err = store.SaveExecution(&core.Execution{ID: "exec-1", Status: core.ExecutionStatusRunning})
+	if !errors.Is(err, core.ErrExecutionCancelled) {
+		t.Errorf("expected core.ErrExecutionCancelled, got %v", err)
+	}
+	loaded, err := store.LoadExecution("exec-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loaded.Status != core.ExecutionStatusCancelled {
+		t.Errorf("expected the execution to stay cancelled, got %s", loaded.Status)
+	}
+	if err := store.SaveExecution(loaded); err != nil {
+		t.Errorf("expected a cancelled execution to be saved, got %v", err)
+	}
+}
@@ -0,0 +1,437 @@
+// Package validation checks workflow definitions without running them, reporting every
+// problem found with its line and column in the workflow file.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sire-run/sire/internal/core"
+	"github.com/sire-run/sire/internal/loader"
+	"gopkg.in/yaml.v3"
+)
+
+// Issue is a problem found in a workflow file. Line and Column are 1-based; they are zero
+// when the problem cannot be tied to a position. File is set for problems in another file,
+// such as one the workflow imports templates from.
+type Issue struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	switch {
+	case i.Line == 0:
+		return i.Message
+	case i.Column == 0:
+		return fmt.Sprintf("%d: %s", i.Line, i.Message)
+	default:
+		return fmt.Sprintf("%d:%d: %s", i.Line, i.Column, i.Message)
+	}
+}
+
+// Validator checks workflow files. By default only the definition itself is checked; the
+// options add checks against the environment the workflow will run in.
+type Validator struct {
+	schemes    map[string]bool // Nil accepts every scheme
+	localTools map[string]bool // Nil accepts every sire:local tool
+}
+
+// Option configures a Validator.
+type Option func(*Validator)
+
+// WithSchemes reports tools whose URI scheme is not one of schemes, e.g. those registered
+// with a core.DispatcherMux.
+func WithSchemes(schemes ...string) Option {
+	return func(v *Validator) {
+		v.schemes = make(map[string]bool, len(schemes))
+		for _, scheme := range schemes {
+			v.schemes[scheme] = true
+		}
+	}
+}
+
+// WithLocalTools reports sire:local tools that are not in tools, e.g. those registered with
+// the in-process server.
+func WithLocalTools(tools ...string) Option {
+	return func(v *Validator) {
+		v.localTools = make(map[string]bool, len(tools))
+		for _, tool := range tools {
+			v.localTools[tool] = true
+		}
+	}
+}
+
+// New creates a new Validator.
+func New(opts ...Option) *Validator {
+	v := &Validator{}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// yamlErrorLine matches the position in yaml.v3 and loader.Decode error messages, e.g.
+// "yaml: line 3: ..." or "line 3, column 5: ...".
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+)(?:, column (\d+))?: (.*)$`)
+
+// ownerPrefix matches the subject of engine validation errors, e.g. "step fetch retry: ...".
+var ownerPrefix = regexp.MustCompile(`^(step|hook|workflow) (\S+?)( retry)?:`)
+
+// Validate checks the YAML workflow definition in data. It checks that the file parses
+// without unknown fields, that matrix steps expand, that step IDs are unique and edges name
+// existing steps without forming cycles, that tool URIs are well formed and can be
+// dispatched, that templates and expressions only reference steps that have run by then,
+// and everything the engine checks before running a workflow, such as retry policies. Values
+// that fail to decode are reported without stopping the other checks. The issues are sorted
+// by position.
+func (v *Validator) Validate(data []byte) []Issue {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return yamlIssues(err)
+	}
+	return v.ValidateNode(&root)
+}
+
+// ValidateNode is like Validate for a workflow document that has already been parsed, e.g.
+// to have its step templates expanded first.
+func (v *Validator) ValidateNode(root *yaml.Node) []Issue {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return []Issue{{Message: "workflow file is empty"}}
+	}
+	c := &checker{validator: v, doc: newDocument(root.Content[0])}
+	workflow, err := loader.Decode(root)
+	if err != nil {
+		// Type errors come with the rest of the workflow, which is still checked.
+		c.issues = append(c.issues, yamlIssues(err)...)
+		if workflow == nil {
+			return c.sorted()
+		}
+	}
+	c.checkSteps(workflow)
+	if err := workflow.ExpandMatrix(); err != nil {
+		for _, err := range flatten(err) {
+			c.addError(err)
+		}
+		return c.sorted()
+	}
+	for _, err := range flatten(workflow.Validate()) {
+		c.addError(err)
+	}
+	for _, err := range flatten(core.ValidateReferences(workflow)) {
+		c.addError(err)
+	}
+	return c.sorted()
+}
+
+// yamlIssues converts a yaml.v3 parse or decode error into issues.
+func yamlIssues(err error) []Issue {
+	messages := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+	issues := make([]Issue, 0, len(messages))
+	for _, message := range messages {
+		issue := Issue{Message: message}
+		if m := yamlErrorLine.FindStringSubmatch(message); m != nil {
+			issue.Line, _ = strconv.Atoi(m[1])
+			issue.Column, _ = strconv.Atoi(m[2])
+			issue.Message = m[3]
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// flatten returns the errors joined in err, recursively.
+func flatten(err error) []error {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, err := range joined.Unwrap() {
+		errs = append(errs, flatten(err)...)
+	}
+	return errs
+}
+
+// checker collects the issues of one workflow file.
+type checker struct {
+	validator *Validator
+	doc       *document
+	issues    []Issue
+}
+
+// add records an issue at node, or without a position if node is nil.
+func (c *checker) add(node *yaml.Node, format string, args ...interface{}) {
+	issue := Issue{Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		issue.Line, issue.Column = node.Line, node.Column
+	}
+	c.issues = append(c.issues, issue)
+}
+
+// addError records an error from core, positioned at the part of the file it is about.
+func (c *checker) addError(err error) {
+	var edgeErr *core.EdgeError
+	var cycleErr *core.CycleError
+	var refErr *core.ReferenceError
+	var matrixErr *core.MatrixError
+	switch {
+	case errors.As(err, &edgeErr):
+		c.add(c.doc.edge(edgeErr.Edge, edgeErr.Step), "%s", err)
+	case errors.As(err, &cycleErr):
+		c.add(c.doc.step(cycleErr.Path[0]), "%s", err)
+	case errors.As(err, &refErr) && refErr.Output != "":
+		c.add(c.doc.outputs[refErr.Output], "%s", err)
+	case errors.As(err, &refErr):
+		c.add(c.doc.step(refErr.StepID), "%s", err)
+	case errors.As(err, &matrixErr):
+		step := c.doc.step(matrixErr.StepID)
+		c.add(valueOr(loader.Field(step, "matrix"), step), "%s", err)
+	default:
+		node := c.doc.root
+		if m := ownerPrefix.FindStringSubmatch(err.Error()); m != nil {
+			if m[1] != "workflow" && c.doc.step(m[2]) != nil {
+				node = c.doc.step(m[2])
+			}
+			if m[3] != "" && loader.Field(node, "retry") != nil {
+				node = loader.Field(node, "retry")
+			}
+		}
+		c.add(node, "%s", err)
+	}
+}
+
+// sorted returns the issues without duplicates, sorted by position.
+func (c *checker) sorted() []Issue {
+	sort.SliceStable(c.issues, func(i, j int) bool {
+		a, b := c.issues[i], c.issues[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	issues := make([]Issue, 0, len(c.issues))
+	seen := make(map[Issue]bool, len(c.issues))
+	for _, issue := range c.issues {
+		if !seen[issue] {
+			seen[issue] = true
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// checkSteps checks step IDs and the tools of steps and hooks. Duplicate hook IDs are
+// reported by the engine's checks. Items that failed to decode are missing from workflow,
+// so a list that no longer lines up with its nodes is left to its decode issues.
+func (c *checker) checkSteps(workflow *core.Workflow) {
+	stepNodes := loader.Items(loader.Field(c.doc.root, "steps"))
+	if len(stepNodes) != len(workflow.Steps) {
+		stepNodes = nil
+	}
+	seen := make(map[string]*yaml.Node, len(workflow.Steps))
+	for i, step := range workflow.Steps[:len(stepNodes)] {
+		node := stepNodes[i]
+		idNode := loader.Field(node, "id")
+		switch first, ok := seen[step.ID]; {
+		case step.ID == "":
+			c.add(node, "step %d has no id", i+1)
+		case ok:
+			c.add(idNode, "duplicate step ID %q (first defined on line %d)", step.ID, first.Line)
+		default:
+			seen[step.ID] = idNode
+		}
+		c.checkStepTools(node, step)
+	}
+	for key, hooks := range map[string][]core.Step{"on_success": workflow.OnSuccess, "on_failure": workflow.OnFailure, "finally": workflow.Finally} {
+		nodes := loader.Items(loader.Field(c.doc.root, key))
+		if len(nodes) != len(hooks) {
+			continue
+		}
+		for i, node := range nodes {
+			c.checkStepTools(node, hooks[i])
+		}
+	}
+}
+
+// checkStepTools checks the tool, compensate and fallback tools of a step or hook.
+func (c *checker) checkStepTools(node *yaml.Node, step core.Step) {
+	owner := "step " + step.ID
+	if c.doc.hooks[node] {
+		owner = "hook " + step.ID
+	}
+	// Signal, timer and sub-workflow steps do not dispatch a tool.
+	noTool := step.Signal != nil || step.Sleep != "" || step.WaitUntil != "" || step.Workflow != ""
+	switch {
+	case step.Tool != "":
+		c.checkTool(valueOr(loader.Field(node, "tool"), node), owner, step.Tool)
+	case !noTool:
+		c.add(node, "%s: tool is required", owner)
+	}
+	if step.Compensate != nil {
+		compensate := loader.Field(node, "compensate")
+		c.checkTool(valueOr(loader.Field(compensate, "tool"), compensate), owner+" compensate", step.Compensate.Tool)
+	}
+	if step.Fallback != nil {
+		fallback := loader.Field(node, "fallback")
+		c.checkTool(valueOr(loader.Field(fallback, "tool"), fallback), owner+" fallback", step.Fallback.Tool)
+	}
+}
+
+// checkTool checks that tool is a well-formed sire:local or mcp tool URI that can be
+// dispatched.
+func (c *checker) checkTool(node *yaml.Node, owner, tool string) {
+	const (
+		localFormat  = "sire:local/service.method"
+		remoteFormat = "mcp:http://host/path#service.method"
+	)
+	if tool == "" {
+		c.add(node, "%s: tool is required", owner)
+		return
+	}
+	u, err := url.Parse(tool)
+	if err != nil || u.Scheme == "" {
+		c.add(node, "%s: malformed tool URI %q (expected %s or %s)", owner, tool, localFormat, remoteFormat)
+		return
+	}
+	if schemes := c.validator.schemes; schemes != nil && !schemes[u.Scheme] {
+		c.add(node, "%s: no dispatcher is registered for scheme %q of tool %q", owner, u.Scheme, tool)
+		return
+	}
+
+	switch u.Scheme {
+	case "sire":
+		service, method, ok := strings.Cut(strings.TrimPrefix(u.Opaque, "local/"), ".")
+		if !strings.HasPrefix(u.Opaque, "local/") || !ok || service == "" || method == "" {
+			c.add(node, "%s: malformed tool URI %q (expected %s)", owner, tool, localFormat)
+			return
+		}
+		if tools := c.validator.localTools; tools != nil && !tools[tool] {
+			c.add(node, "%s: tool %q is not registered", owner, tool)
+		}
+	case "mcp":
+		rpc, err := url.Parse(strings.TrimPrefix(tool, "mcp:"))
+		if err != nil || (rpc.Scheme != "http" && rpc.Scheme != "https") || rpc.Host == "" || rpc.Fragment == "" {
+			c.add(node, "%s: malformed tool URI %q (expected %s)", owner, tool, remoteFormat)
+		}
+	}
+}
+
+// document indexes the nodes of a workflow file that issues are reported at.
+type document struct {
+	root    *yaml.Node
+	steps   map[string]*yaml.Node // Step and hook mappings by ID, the first one for duplicates
+	hooks   map[*yaml.Node]bool
+	outputs map[string]*yaml.Node // Output values by name
+}
+
+func newDocument(root *yaml.Node) *document {
+	d := &document{
+		root:    root,
+		steps:   make(map[string]*yaml.Node),
+		hooks:   make(map[*yaml.Node]bool),
+		outputs: make(map[string]*yaml.Node),
+	}
+	index := func(node *yaml.Node) {
+		if id := loader.Field(node, "id"); id != nil && d.steps[id.Value] == nil {
+			d.steps[id.Value] = node
+		}
+	}
+	for _, node := range loader.Items(loader.Field(root, "steps")) {
+		index(node)
+	}
+	for _, key := range []string{"on_success", "on_failure", "finally"} {
+		for _, node := range loader.Items(loader.Field(root, key)) {
+			index(node)
+			d.hooks[node] = true
+		}
+	}
+	for _, node := range loader.Items(loader.Field(root, "outputs")) {
+		if name := loader.Field(node, "name"); name != nil && d.outputs[name.Value] == nil {
+			d.outputs[name.Value] = valueOr(loader.Field(node, "value"), node)
+		}
+	}
+	return d
+}
+
+// step returns the mapping of the step or hook with the given ID, or of the matrix step
+// that a matrix instance ID such as deploy[region=eu] was expanded from. It returns nil for
+// unknown IDs.
+func (d *document) step(id string) *yaml.Node {
+	if node, ok := d.steps[id]; ok {
+		return node
+	}
+	if base, _, ok := strings.Cut(id, "["); ok && strings.HasSuffix(id, "]") {
+		return d.steps[base]
+	}
+	return nil
+}
+
+// edge returns the node of the unknown step in an edge, which is either one end of an
+// entry in edges or a depends_on entry of the edge's target.
+func (d *document) edge(edge core.Edge, unknown string) *yaml.Node {
+	for _, node := range loader.Items(loader.Field(d.root, "edges")) {
+		from, to := stepScalar(loader.Field(node, "from"), edge.From), stepScalar(loader.Field(node, "to"), edge.To)
+		if from == nil || to == nil {
+			continue
+		}
+		if unknown == edge.From {
+			return from
+		}
+		return to
+	}
+	if dependency := stepScalar(loader.Field(d.step(edge.To), "depends_on"), edge.From); dependency != nil {
+		return dependency
+	}
+	return valueOr(loader.Field(d.root, "edges"), d.root)
+}
+
+// scalar returns node if it is the scalar value, or the item of a sequence node that is.
+func scalar(node *yaml.Node, value string) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	if node.Kind == yaml.ScalarNode && node.Value == value {
+		return node
+	}
+	for _, item := range loader.Items(node) {
+		if item.Kind == yaml.ScalarNode && item.Value == value {
+			return item
+		}
+	}
+	return nil
+}
+
+// stepScalar is like scalar for a step ID, also matching the ID of the matrix step that a
+// matrix instance was expanded from.
+func stepScalar(node *yaml.Node, id string) *yaml.Node {
+	if found := scalar(node, id); found != nil {
+		return found
+	}
+	if base, _, ok := strings.Cut(id, "["); ok {
+		return scalar(node, base)
+	}
+	return nil
+}
+
+// valueOr returns node, or fallback if node is nil.
+func valueOr(node, fallback *yaml.Node) *yaml.Node {
+	if node != nil {
+		return node
+	}
+	return fallback
+}
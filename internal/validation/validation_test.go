@@ -0,0 +1,233 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+// hasIssue reports whether issues contain one at line whose message contains text.
+func hasIssue(issues []Issue, line int, text string) bool {
+	for _, issue := range issues {
+		if issue.Line == line && strings.Contains(issue.Message, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidator_Validate_Valid(t *testing.T) {
+	data := `
+id: valid
+steps:
+  - id: fetch
+    tool: sire:local/http.get
+  - id: upload
+    tool: mcp:https://tools.example.com/rpc#storage.put
+    params:
+      body: "{{ .steps.fetch.output.body }}"
+      short: "{{ .fetch.output.body }}"
+    retry:
+      max_attempts: 3
+  - id: approve
+    signal: {}
+    depends_on: [upload]
+edges:
+  - from: fetch
+    to: upload
+`
+	v := New(WithSchemes("mcp", "sire"), WithLocalTools("sire:local/http.get"))
+	if issues := v.Validate([]byte(data)); len(issues) != 0 {
+		t.Errorf("expected no issues, got %v", issues)
+	}
+}
+
+func TestValidator_Validate(t *testing.T) {
+	data := `id: broken
+steps:
+  - id: fetch
+    tool: sire:local/http.get
+  - id: fetch
+    tool: sire:local/http.post
+  - id: parse
+    tool: sire:local/transform
+  - id: store
+    tool: ftp://example.com/upload
+    retry:
+      backoff: quadratic
+  - id: notify
+    tool: sire:local/chat.send
+    params:
+      text: "{{ .steps.store.output }}"
+  - id: remote
+    tool: mcp:example.com#svc.call
+    depends_on: [parse, missing]
+  - id: empty
+edges:
+  - from: parse
+    to: store
+  - from: store
+    to: parse
+outputs:
+  - name: result
+    value: steps.nope.output
+`
+	v := New(WithSchemes("mcp", "sire"), WithLocalTools("sire:local/http.get", "sire:local/http.post"))
+	issues := v.Validate([]byte(data))
+
+	for _, want := range []struct {
+		line int
+		text string
+	}{
+		{5, `duplicate step ID "fetch" (first defined on line 3)`},
+		{7, `workflow has a cycle: parse -> store -> parse`},
+		{8, `step parse: malformed tool URI "sire:local/transform"`},
+		{10, `step store: no dispatcher is registered for scheme "ftp"`},
+		{12, `step store retry: unknown backoff "quadratic"`},
+		{13, `step notify: references step "store", which is not an ancestor`},
+		{14, `step notify: tool "sire:local/chat.send" is not registered`},
+		{18, `step remote: malformed tool URI "mcp:example.com#svc.call"`},
+		{19, `edge missing -> remote references unknown step "missing"`},
+		{20, `step empty: tool is required`},
+		{28, `output "result": references unknown step "nope"`},
+	} {
+		if !hasIssue(issues, want.line, want.text) {
+			t.Errorf("expected an issue on line %d containing %q, got:\n%v", want.line, want.text, issues)
+		}
+	}
+	for i := 1; i < len(issues); i++ {
+		if issues[i].Line < issues[i-1].Line {
+			t.Errorf("expected issues sorted by line, got %v", issues)
+		}
+	}
+}
+
+func TestValidator_Validate_TemplateFields(t *testing.T) {
+	data := `id: templates
+steps:
+  - id: fetch
+    tool: sire:local/http.get
+    params:
+      url: "{{ .workflow.params.url }}"
+  - id: each
+    tool: sire:local/http.get
+    foreach: steps.fetch.output.urls
+    params:
+      url: "{{ .item }}"
+      names: "{{ range .steps.fetch.output.users }}{{ .name }}{{ end }}"
+      broken: "{{ .steps.fetch "
+edges:
+  - from: fetch
+    to: each
+`
+	issues := New().Validate([]byte(data))
+	if !hasIssue(issues, 3, "unknown field .workflow.params") {
+		t.Errorf("expected an unknown field issue, got %v", issues)
+	}
+	if !hasIssue(issues, 7, "step each: invalid template") {
+		t.Errorf("expected an invalid template issue, got %v", issues)
+	}
+	if len(issues) != 2 {
+		t.Errorf("expected 2 issues, got %v", issues)
+	}
+}
+
+func TestValidator_Validate_YAMLErrors(t *testing.T) {
+	issues := New().Validate([]byte("id: bad\nsteps:\n  - id: a\n    retry:\n      max_attempts: many\n"))
+	if !hasIssue(issues, 5, "cannot unmarshal") {
+		t.Errorf("expected a decode issue on line 5, got %v", issues)
+	}
+
+	// Decode issues do not stop the other checks, even where a step failed to decode.
+	data := "id: bad\nsteps:\n  - id: a\n    tool: sire:local/a.run\n    retry:\n      max_attempts: many\n  - id: a\n    tool: nope\n"
+	issues = New().Validate([]byte(data))
+	if !hasIssue(issues, 6, "cannot unmarshal") || !hasIssue(issues, 7, `duplicate step ID "a"`) || !hasIssue(issues, 8, "malformed tool URI") {
+		t.Errorf("expected the decode issue and the issues after it, got %v", issues)
+	}
+	issues = New().Validate([]byte("id: bad\nsteps:\n  - oops\n  - id: b\n    tool: nope\nedges:\n  - from: b\n    to: c\n"))
+	if !hasIssue(issues, 3, "cannot unmarshal") || !hasIssue(issues, 8, `unknown step "c"`) {
+		t.Errorf("expected the decode issue and the edge issue, got %v", issues)
+	}
+
+	issues = New().Validate([]byte("id: bad\nsteps: [\n"))
+	if len(issues) != 1 || issues[0].Line == 0 {
+		t.Errorf("expected one positioned syntax issue, got %v", issues)
+	}
+
+	issues = New().Validate(nil)
+	if len(issues) != 1 || issues[0].Message != "workflow file is empty" {
+		t.Errorf("expected an empty file issue, got %v", issues)
+	}
+}
+
+func TestValidator_Validate_UnknownFields(t *testing.T) {
+	data := "id: typos\nsteps:\n  - id: fetch\n    tool: sire:local/http.get\n    retires:\n      max_attempts: 3\n"
+	issues := New().Validate([]byte(data))
+	if len(issues) != 1 || issues[0].Line != 5 || issues[0].Column != 5 || issues[0].Message != `unknown field "retires" (did you mean "retry"?)` {
+		t.Errorf("expected an unknown field issue at 5:5, got %v", issues)
+	}
+}
+
+func TestValidator_Validate_Matrix(t *testing.T) {
+	data := `id: fanout
+steps:
+  - id: deploy
+    tool: sire:local/deploy.run
+    matrix:
+      region: [eu, us]
+      env: [staging, prod]
+    params:
+      target: "{{ .matrix.region }}"
+  - id: report
+    tool: sire:local/report.send
+    depends_on: [deploy]
+    params:
+      urls: "{{ .steps.deploy.output }}"
+  - id: audit
+    tool: sire:local/audit.log
+    params:
+      urls: "{{ .deploy.output }}"
+  - id: broken
+    tool: sire:local/deploy.run
+    matrix:
+      region: []
+`
+	issues := New().Validate([]byte(data))
+	if !hasIssue(issues, 22, "step broken: matrix axis region has no values") {
+		t.Errorf("expected an empty axis issue on line 22, got %v", issues)
+	}
+
+	data = strings.Replace(data, "      region: []\n", "      region: [eu]\n", 1)
+	issues = New().Validate([]byte(data))
+	if len(issues) != 1 || !hasIssue(issues, 15, `step audit: references step "deploy", which is not an ancestor`) {
+		t.Errorf("expected only the audit reference issue on line 15, got %v", issues)
+	}
+}
+
+func TestValidator_Validate_SubWorkflowSteps(t *testing.T) {
+	data := `id: orders
+steps:
+  - id: notify
+    workflow: notify.yml
+  - id: notify_all
+    workflow: notify.yml
+    foreach: inputs.orders
+  - id: send
+    workflow: notify.yml
+    tool: sire:local/chat.send
+`
+	issues := New().Validate([]byte(data))
+	for _, want := range []struct {
+		line int
+		text string
+	}{
+		{5, "step notify_all: sub-workflow steps cannot set tool or foreach"},
+		{8, "step send: sub-workflow steps cannot set tool or foreach"},
+	} {
+		if !hasIssue(issues, want.line, want.text) {
+			t.Errorf("expected an issue on line %d containing %q, got:\n%v", want.line, want.text, issues)
+		}
+	}
+	if len(issues) != 2 {
+		t.Errorf("expected only the two sub-workflow issues, got %v", issues)
+	}
+}